@@ -2,24 +2,35 @@ package cliout
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // newTestOutput creates an Output that writes to a buffer with color enabled
 // and level set to the lowest (LevelTrace) so all messages are captured.
 func newTestOutput() (*Output, *bytes.Buffer) {
 	var buf bytes.Buffer
-	o := &Output{
+	o := &Output{outputState: &outputState{
 		writer:       &buf,
 		level:        LevelTrace,
 		prefix:       defaultPrefix,
 		hasPrefix:    true,
 		theme:        ThemeDefault,
 		colorEnabled: false, // disabled by default in tests for easy string matching
+		profile:      ProfileTrueColor,
 		exitFunc:     os.Exit,
-	}
+	}}
 	return o, &buf
 }
 
@@ -584,6 +595,176 @@ func TestSetWriter(t *testing.T) {
 	}
 }
 
+// --- Per-level writer tests ---
+
+func TestNewRoutesDiagnosticsToStderr(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
+	o := New()
+	for _, l := range []Level{LevelTrace, LevelDebug, LevelInfo} {
+		if w := o.writerFor(l); w != os.Stdout {
+			t.Errorf("expected %s to go to stdout, got %v", l, w)
+		}
+	}
+	for _, l := range []Level{LevelWarn, LevelError} {
+		if w := o.writerFor(l); w != os.Stderr {
+			t.Errorf("expected %s to go to stderr, got %v", l, w)
+		}
+	}
+}
+
+func TestSetWriterRange(t *testing.T) {
+	o, out := newTestOutput()
+	var diag bytes.Buffer
+	o.SetWriterRange(LevelWarn, LevelError, &diag)
+
+	o.Info("info line")
+	o.Warn("warn line")
+	o.Error("error line")
+	o.Success("success line")
+
+	if got := out.String(); !strings.Contains(got, "info line") || !strings.Contains(got, "success line") {
+		t.Fatalf("expected info and success on main writer, got %q", got)
+	}
+	if strings.Contains(out.String(), "warn line") || strings.Contains(out.String(), "error line") {
+		t.Fatalf("diagnostics leaked to main writer: %q", out.String())
+	}
+	if got := diag.String(); !strings.Contains(got, "warn line") || !strings.Contains(got, "error line") {
+		t.Fatalf("expected warn and error on diagnostic writer, got %q", got)
+	}
+}
+
+func TestSetLevelWriter(t *testing.T) {
+	o, out := newTestOutput()
+	var debug bytes.Buffer
+	o.SetLevelWriter(LevelDebug, &debug)
+
+	o.Debug("debug line")
+	o.Trace("trace line")
+
+	if !strings.Contains(debug.String(), "debug line") {
+		t.Fatalf("expected debug on its own writer, got %q", debug.String())
+	}
+	if strings.Contains(out.String(), "debug line") || !strings.Contains(out.String(), "trace line") {
+		t.Fatalf("unexpected main writer content: %q", out.String())
+	}
+}
+
+func TestFatalUsesErrorWriter(t *testing.T) {
+	o, out := newTestOutput()
+	var diag bytes.Buffer
+	o.SetLevelWriter(LevelError, &diag)
+	o.exitFunc = func(int) {}
+
+	o.Fatal("fatal line")
+	if !strings.Contains(diag.String(), "fatal line") || out.Len() != 0 {
+		t.Fatalf("expected fatal on error writer only, got main=%q diag=%q", out.String(), diag.String())
+	}
+}
+
+func TestSetWriterReplacesLevelRouting(t *testing.T) {
+	o, _ := newTestOutput()
+	var diag, all bytes.Buffer
+	o.SetWriterRange(LevelWarn, LevelError, &diag)
+	o.SetWriter(&all)
+
+	o.Warn("warn line")
+	if diag.Len() != 0 || !strings.Contains(all.String(), "warn line") {
+		t.Fatalf("SetWriter should override per-level routing, got diag=%q all=%q", diag.String(), all.String())
+	}
+}
+
+func TestColorDetectedPerDestination(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetTheme(ThemeDracula)
+	// Simulate stdout piped to a file while stderr is a terminal.
+	for l := LevelTrace; l <= LevelInfo; l++ {
+		o.plain[l] = true
+	}
+
+	o.Info("plain info")
+	o.Warn("colored warn")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if strings.Contains(lines[0], "\033[") {
+		t.Fatalf("expected plain info line, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "\033[") {
+		t.Fatalf("expected colored warn line, got %q", lines[1])
+	}
+	if got := o.Colorize("x", ColorRed); got != "x" {
+		t.Fatalf("Colorize should follow the info destination, got %q", got)
+	}
+}
+
+func TestDetectColorMarksNonTerminalFiles(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	o, _ := newTestOutput()
+	var buf bytes.Buffer
+	o.SetWriter(&buf)
+	o.SetLevelWriter(LevelError, f)
+	o.detectColor()
+
+	if !o.plain[LevelError] {
+		t.Fatal("expected error level to be plain when writing to a regular file")
+	}
+	if o.plain[LevelInfo] {
+		t.Fatal("non-file writers should not be marked plain")
+	}
+}
+
+func TestSetLevelWriterFileIsPlain(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetLevelWriter(LevelError, f)
+	o.Error("to the log")
+	o.Warn("to the terminal")
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "to the log") {
+		t.Fatalf("expected error in file, got %q", data)
+	}
+	if strings.Contains(string(data), "\033[") {
+		t.Fatalf("expected no escape codes in file, got %q", data)
+	}
+	if !strings.Contains(buf.String(), "\033[") {
+		t.Fatalf("other levels should keep color, got %q", buf.String())
+	}
+}
+
+func TestSetColorEnabledOverridesDetection(t *testing.T) {
+	o, buf := newTestOutput()
+	o.plain[LevelInfo] = true
+	o.SetColorEnabled(true)
+	o.SetMessageColor(ColorRed)
+	o.Info("forced")
+	if !strings.Contains(buf.String(), "\033[31m") {
+		t.Fatalf("expected SetColorEnabled(true) to force color, got %q", buf.String())
+	}
+}
+
+func TestUseUnixStreams(t *testing.T) {
+	o, _ := newTestOutput()
+	o.UseUnixStreams()
+	if o.writerFor(LevelInfo) != os.Stdout || o.writerFor(LevelError) != os.Stderr {
+		t.Fatal("expected Unix stream routing after UseUnixStreams")
+	}
+}
+
 // --- Default instance tests ---
 
 func TestDefaultInstance(t *testing.T) {
@@ -602,39 +783,71 @@ func TestDefaultInstance(t *testing.T) {
 func setupDefaultForTest() (*bytes.Buffer, func()) {
 	d := Default()
 	origWriter := d.writer
+	origWriters := d.writers
+	origPlain := d.plain
+	origTTY := d.tty
 	origLevel := d.level
 	origTheme := d.theme
+	origPendingTheme := d.pendingTheme
+	origFormat := d.format
 	origPrefix := d.prefix
 	origHasPrefix := d.hasPrefix
 	origColor := d.colorEnabled
+	origProfile := d.profile
 	origPrefixColor := d.prefixColor
 	origMessageColor := d.messageColor
 	origNoColorEnv := d.noColorEnv
 	origExitFunc := d.exitFunc
+	origReader := d.reader
+	origGroupStyle := d.groupStyle
+	origWidth := d.width
+	origMultiline := d.multiline
 
 	var buf bytes.Buffer
 	d.writer = &buf
+	d.writers = [numLevels]io.Writer{}
+	d.plain = [numLevels]bool{}
+	d.tty = [numLevels]bool{}
 	d.level = LevelTrace
 	d.colorEnabled = false
+	d.profile = ProfileTrueColor
 	d.noColorEnv = false
 	d.theme = ThemeDefault
+	d.pendingTheme = ""
+	d.format = FormatText
 	d.prefix = defaultPrefix
 	d.hasPrefix = true
 	d.prefixColor = ColorDefault
 	d.messageColor = ColorDefault
 	d.exitFunc = os.Exit
+	d.reader = nil
+	d.lineReader = nil
+	d.groupStyle = GroupIndent
+	d.width = 0
+	d.multiline = MultilineAlign
 
 	cleanup := func() {
 		d.writer = origWriter
+		d.writers = origWriters
+		d.plain = origPlain
+		d.tty = origTTY
 		d.level = origLevel
 		d.theme = origTheme
+		d.pendingTheme = origPendingTheme
+		d.format = origFormat
 		d.prefix = origPrefix
 		d.hasPrefix = origHasPrefix
 		d.colorEnabled = origColor
+		d.profile = origProfile
 		d.noColorEnv = origNoColorEnv
 		d.prefixColor = origPrefixColor
 		d.messageColor = origMessageColor
 		d.exitFunc = origExitFunc
+		d.reader = origReader
+		d.groupStyle = origGroupStyle
+		d.width = origWidth
+		d.multiline = origMultiline
+		d.lineReader = nil
 	}
 	return &buf, cleanup
 }
@@ -1056,6 +1269,7 @@ func TestPackageLevelColorize(t *testing.T) {
 // --- CLI_THEME environment variable tests ---
 
 func TestNewRespectsCliThemeEnv(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_THEME", "Dracula")
 
 	o := New()
@@ -1065,6 +1279,7 @@ func TestNewRespectsCliThemeEnv(t *testing.T) {
 }
 
 func TestNewCliThemeEnvCaseInsensitive(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_THEME", "tokyo night storm")
 
 	o := New()
@@ -1074,6 +1289,7 @@ func TestNewCliThemeEnvCaseInsensitive(t *testing.T) {
 }
 
 func TestNewCliThemeEnvUnknownFallsBackToDefault(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_THEME", "nonexistent-theme")
 
 	o := New()
@@ -1083,6 +1299,7 @@ func TestNewCliThemeEnvUnknownFallsBackToDefault(t *testing.T) {
 }
 
 func TestNewCliThemeEnvEmptyFallsBackToDefault(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_THEME", "")
 
 	o := New()
@@ -1092,6 +1309,7 @@ func TestNewCliThemeEnvEmptyFallsBackToDefault(t *testing.T) {
 }
 
 func TestNewCliThemeEnvUnsetUsesDefault(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_THEME", "")
 	os.Unsetenv("CLI_THEME") //nolint:errcheck // test cleanup handled by t.Setenv
 
@@ -1102,6 +1320,7 @@ func TestNewCliThemeEnvUnsetUsesDefault(t *testing.T) {
 }
 
 func TestNewCliThemeWithNoColorStillDisablesColor(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_THEME", "Dracula")
 	t.Setenv("NO_COLOR", "1")
 
@@ -1117,6 +1336,7 @@ func TestNewCliThemeWithNoColorStillDisablesColor(t *testing.T) {
 // --- CLI_PREFIX environment variable tests ---
 
 func TestNewRespectsCliPrefixEnv(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_PREFIX", "->")
 
 	o := New()
@@ -1129,6 +1349,7 @@ func TestNewRespectsCliPrefixEnv(t *testing.T) {
 }
 
 func TestNewCliPrefixEnvEmptyClearsPrefix(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_PREFIX", "")
 
 	o := New()
@@ -1141,6 +1362,7 @@ func TestNewCliPrefixEnvEmptyClearsPrefix(t *testing.T) {
 }
 
 func TestNewCliPrefixEnvUnsetUsesDefault(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_PREFIX", "")
 	os.Unsetenv("CLI_PREFIX") //nolint:errcheck // test cleanup handled by t.Setenv
 
@@ -1154,6 +1376,7 @@ func TestNewCliPrefixEnvUnsetUsesDefault(t *testing.T) {
 }
 
 func TestNewSetPrefixOverridesCliPrefixEnv(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_PREFIX", "->")
 
 	o := New()
@@ -1168,6 +1391,7 @@ func TestNewSetPrefixOverridesCliPrefixEnv(t *testing.T) {
 }
 
 func TestNewCliPrefixEnvOutput(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_PREFIX", "::")
 
 	o := New()
@@ -1184,6 +1408,7 @@ func TestNewCliPrefixEnvOutput(t *testing.T) {
 }
 
 func TestNewCliPrefixEnvEmptyOutput(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_PREFIX", "")
 
 	o := New()
@@ -1200,6 +1425,7 @@ func TestNewCliPrefixEnvEmptyOutput(t *testing.T) {
 }
 
 func TestNewCliPrefixWithCliTheme(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_PREFIX", "=>")
 	t.Setenv("CLI_THEME", "Dracula")
 
@@ -1213,6 +1439,7 @@ func TestNewCliPrefixWithCliTheme(t *testing.T) {
 }
 
 func TestNewSetThemeOverridesCliThemeEnv(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("CLI_THEME", "Dracula")
 
 	o := New()
@@ -1229,6 +1456,7 @@ func TestNewSetThemeOverridesCliThemeEnv(t *testing.T) {
 // --- NO_COLOR enforcement tests ---
 
 func TestSetColorEnabledBlockedByNoColor(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("NO_COLOR", "1")
 
 	o := New()
@@ -1244,6 +1472,7 @@ func TestSetColorEnabledBlockedByNoColor(t *testing.T) {
 }
 
 func TestNoColorPreventsAnsiCodesAfterSetColorEnabled(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("NO_COLOR", "1")
 
 	o := New()
@@ -1263,6 +1492,7 @@ func TestNoColorPreventsAnsiCodesAfterSetColorEnabled(t *testing.T) {
 }
 
 func TestNoColorBlocksColorizeOutput(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("NO_COLOR", "1")
 
 	o := New()
@@ -1278,6 +1508,7 @@ func TestNoColorBlocksColorizeOutput(t *testing.T) {
 }
 
 func TestNoColorEmptyValueStillDisables(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("NO_COLOR", "")
 
 	o := New()
@@ -1289,6 +1520,7 @@ func TestNoColorEmptyValueStillDisables(t *testing.T) {
 }
 
 func TestNoColorWithThemeAndPrefixProducesPlainOutput(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
 	t.Setenv("NO_COLOR", "1")
 	t.Setenv("CLI_THEME", "Dracula")
 	t.Setenv("CLI_PREFIX", "::")
@@ -1337,3 +1569,3744 @@ func TestSetColorEnabledWorksWithoutNoColor(t *testing.T) {
 		t.Fatalf("expected no ANSI codes after disabling color, got %q", got)
 	}
 }
+
+// --- Concurrency tests ---
+
+// writeRecorder is an io.Writer that records each Write call separately so
+// tests can assert that every line arrives in a single write.
+type writeRecorder struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestPrintWritesWholeLineInSingleWrite(t *testing.T) {
+	o, _ := newTestOutput()
+	rec := &writeRecorder{}
+	o.SetWriter(rec)
+	o.SetColorEnabled(true)
+	o.SetTheme(ThemeDracula)
+
+	o.Info("one")
+	o.Warn("two")
+	if len(rec.writes) != 2 {
+		t.Fatalf("expected 2 writes, got %d: %q", len(rec.writes), rec.writes)
+	}
+	for _, w := range rec.writes {
+		if !strings.HasSuffix(w, "\n") || strings.Count(w, "\n") != 1 {
+			t.Errorf("expected exactly one complete line per write, got %q", w)
+		}
+	}
+}
+
+func TestConcurrentPrintDoesNotInterleave(t *testing.T) {
+	o, buf := newTestOutput()
+
+	const goroutines = 16
+	const perGoroutine = 200
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				o.Infof("goroutine-%d message-%d %s", g, i, strings.Repeat("x", 64))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != goroutines*perGoroutine {
+		t.Fatalf("expected %d lines, got %d", goroutines*perGoroutine, len(lines))
+	}
+	suffix := " " + strings.Repeat("x", 64)
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "» goroutine-") || !strings.HasSuffix(line, suffix) {
+			t.Fatalf("interleaved or corrupted line: %q", line)
+		}
+	}
+}
+
+func TestConcurrentPrintAndConfigure(t *testing.T) {
+	o, buf := newTestOutput()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	// Reconfigure continuously while other goroutines print.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		themes := []Theme{ThemeDefault, ThemeDracula, ThemeNord}
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			o.SetLevel(Level(i % 2 * int(LevelInfo)))
+			o.SetTheme(themes[i%len(themes)])
+			o.SetColorEnabled(i%2 == 0)
+			o.SetPrefixColor(ColorRed)
+			o.SetMessageColor(ColorBlue)
+			if i%3 == 0 {
+				o.ClearPrefix()
+			} else {
+				o.SetPrefix(fmt.Sprintf("[%d]", i%10))
+			}
+			var w bytes.Buffer
+			o.SetWriter(&w)
+			o.SetWriter(buf)
+			_ = o.Colorize("x", ColorGreen)
+		}
+	}()
+
+	var printers sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		printers.Add(1)
+		go func(g int) {
+			defer printers.Done()
+			for i := 0; i < 200; i++ {
+				o.Infof("g%d-%d", g, i)
+				o.Debugf("g%d-%d", g, i)
+				o.Success("done")
+			}
+		}(g)
+	}
+	printers.Wait()
+	close(stop)
+	wg.Wait()
+}
+
+func TestConcurrentPackageLevelFunctions(t *testing.T) {
+	buf, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				Infof("worker %d step %d", g, i)
+				Warnf("worker %d warning %d", g, i)
+				SetTheme(ThemeNord)
+				SetLevel(LevelTrace)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 8*100*2 {
+		t.Fatalf("expected %d lines, got %d", 8*100*2, len(lines))
+	}
+}
+
+// --- Spinner tests ---
+
+// newAnimatedTestOutput returns a test Output that behaves as if its info
+// destination were a color terminal, so live regions are animated.
+func newAnimatedTestOutput() (*Output, *bytes.Buffer) {
+	o, buf := newTestOutput()
+	o.colorEnabled = true
+	for l := range o.tty {
+		o.tty[l] = true
+	}
+	return o, buf
+}
+
+func TestSpinnerNonTTYPrintsStartAndEndLines(t *testing.T) {
+	o, buf := newTestOutput()
+	s := o.Spinner("building")
+	s.Update("still building")
+	s.Success("built")
+
+	want := "» building\n» built\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestSpinnerNonTTYFinishVariants(t *testing.T) {
+	o, buf := newTestOutput()
+	o.Spinner("a").Warn("warned")
+	o.Spinner("b").Error("failed")
+	o.Spinner("c").Stop()
+
+	want := "» a\n» warned\n» b\n» failed\n» c\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestSpinnerNotAnimatedWhenColorDisabled(t *testing.T) {
+	o, buf := newAnimatedTestOutput()
+	o.SetColorEnabled(false)
+	o.Spinner("working").Success("done")
+	if strings.Contains(buf.String(), "\r") {
+		t.Fatalf("expected no in-place redraws without color, got %q", buf.String())
+	}
+}
+
+func TestSpinnerNotAnimatedOnDumbTerminal(t *testing.T) {
+	o, buf := newAnimatedTestOutput()
+	o.SetColorProfile(ProfileNone)
+	o.Spinner("working").Success("done")
+	if strings.Contains(buf.String(), "\r") || strings.Contains(buf.String(), "\033[") {
+		t.Fatalf("expected plain start and end lines, got %q", buf.String())
+	}
+	if o.live != nil {
+		t.Fatal("expected no live area with ProfileNone")
+	}
+}
+
+func TestSpinnerRespectsLevel(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetLevel(LevelWarn)
+	s := o.Spinner("hidden")
+	s.Success("also hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output at warn level, got %q", buf.String())
+	}
+	o.Spinner("hidden").Error("shown")
+	if buf.String() != "» shown\n" {
+		t.Fatalf("expected only the error line, got %q", buf.String())
+	}
+}
+
+func TestSpinnerAnimatesOnTerminal(t *testing.T) {
+	orig := spinnerInterval
+	spinnerInterval = time.Millisecond
+	defer func() { spinnerInterval = orig }()
+
+	o, buf := newAnimatedTestOutput()
+	s := o.Spinner("working")
+	time.Sleep(20 * time.Millisecond)
+	s.Update("nearly there")
+	s.Success("done")
+
+	got := buf.String()
+	if !strings.Contains(got, ansiClearLine) {
+		t.Fatalf("expected in-place redraws, got %q", got)
+	}
+	if !strings.Contains(got, ColorCyan.apply(spinnerFrames[0], true)+" working") {
+		t.Fatalf("expected first frame in prefix color, got %q", got)
+	}
+	if !strings.Contains(got, "nearly there") {
+		t.Fatalf("expected updated message to be drawn, got %q", got)
+	}
+
+	ref, want := newTestOutput()
+	ref.SetColorEnabled(true)
+	ref.Success("done")
+	if !strings.HasSuffix(got, ansiClearLine+want.String()) {
+		t.Fatalf("expected final line rendered like Success, got %q", got)
+	}
+	if o.live != nil {
+		t.Fatal("expected spinner to detach from output when finished")
+	}
+}
+
+func TestSpinnerRenderFlattensNewlines(t *testing.T) {
+	o, _ := newAnimatedTestOutput()
+	o.SetColorEnabled(false)
+	s := o.Spinner("first\nsecond\r\nthird")
+	defer s.Stop()
+
+	o.mu.Lock()
+	got := s.render()
+	o.mu.Unlock()
+	if strings.ContainsAny(got, "\r\n") {
+		t.Fatalf("expected a single line, got %q", got)
+	}
+	if !strings.HasSuffix(got, "first second third") {
+		t.Fatalf("expected line breaks drawn as spaces, got %q", got)
+	}
+}
+
+func TestLiveAreaTruncatesRowsToWidth(t *testing.T) {
+	o, _ := newAnimatedTestOutput()
+	o.SetWidth(40)
+	long := strings.Repeat("a long status message ", 5)
+	s := o.Spinner(long)
+	defer s.Stop()
+	p := o.Progress(long, 100)
+	defer p.Stop()
+
+	var buf bytes.Buffer
+	o.mu.Lock()
+	a := o.live
+	a.w = &buf
+	a.drawn = 0
+	a.redraw()
+	a.w = o.writerFor(LevelInfo)
+	o.mu.Unlock()
+
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 rows, got %q", buf.String())
+	}
+	for _, line := range lines {
+		if w := visibleWidth(line); w > 40 {
+			t.Fatalf("expected rows of at most 40 columns, got %d in %q", w, line)
+		}
+	}
+}
+
+func TestSpinnerPrintScrollsAbove(t *testing.T) {
+	o, buf := newAnimatedTestOutput()
+	s := o.Spinner("working")
+	o.Info("log line")
+	s.Stop()
+
+	got := buf.String()
+	idx := strings.Index(got, "log line\n")
+	if idx < 0 {
+		t.Fatalf("expected log line in output, got %q", got)
+	}
+	before, after := got[:idx], got[idx:]
+	if !strings.HasSuffix(strings.TrimSuffix(before, ColorCyan.apply("»", true)+" "), ansiClearLine) {
+		t.Fatalf("expected spinner cleared before log line, got %q", got)
+	}
+	if !strings.Contains(after, spinnerFrames[0]) {
+		t.Fatalf("expected spinner redrawn after log line, got %q", got)
+	}
+}
+
+func TestSpinnerConcurrentUse(t *testing.T) {
+	orig := spinnerInterval
+	spinnerInterval = time.Millisecond
+	defer func() { spinnerInterval = orig }()
+
+	o, _ := newAnimatedTestOutput()
+	s := o.Spinner("working")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				s.Update(fmt.Sprintf("step %d.%d", i, j))
+				o.Infof("log %d.%d", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	s.Success("done")
+	s.Error("ignored: already finished")
+}
+
+// --- Progress bar tests ---
+
+// fakeClock replaces timeNow with a manually advanced clock for the
+// duration of a test.
+func fakeClock(t *testing.T) *time.Time {
+	t.Helper()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	orig := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = orig })
+	return &now
+}
+
+func TestProgressNonTTYThrottledUpdates(t *testing.T) {
+	now := fakeClock(t)
+	o, buf := newTestOutput()
+
+	p := o.Progress("downloading", 100)
+	*now = now.Add(time.Second)
+	p.Add(10) // within the throttle interval: no output
+	*now = now.Add(2 * time.Second)
+	p.Add(20) // 30/100 after 3s
+	*now = now.Add(100 * time.Millisecond)
+	p.Add(10) // throttled again
+	p.Success("downloaded")
+
+	want := "» downloading\n" +
+		"» downloading 30% (30/100, 10/s, ETA 7s)\n" +
+		"» downloaded\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestProgressUnknownTotal(t *testing.T) {
+	now := fakeClock(t)
+	o, buf := newTestOutput()
+
+	p := o.Progress("scanning", 0)
+	*now = now.Add(4 * time.Second)
+	p.Add(8)
+	p.Stop()
+
+	want := "» scanning\n» scanning (8, 2/s)\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestProgressRenderOnTerminal(t *testing.T) {
+	now := fakeClock(t)
+	o, buf := newAnimatedTestOutput()
+	o.SetTheme(ThemeDracula)
+
+	p := o.Progress("uploading", 1000)
+	*now = now.Add(time.Second)
+	p.Add(500)
+
+	got := buf.String()
+	if !strings.Contains(got, ansiClearLine) {
+		t.Fatalf("expected in-place redraw, got %q", got)
+	}
+	filled := ThemeDracula.PrefixColor.apply(strings.Repeat("█", progressBarWidth/2), true)
+	empty := ThemeDracula.DebugColor.apply(strings.Repeat("░", progressBarWidth/2), true)
+	if !strings.Contains(got, filled+empty+"  50% ") {
+		t.Fatalf("expected half-filled themed bar, got %q", got)
+	}
+	if !strings.Contains(got, "500/1000  500/s  ETA 1s") {
+		t.Fatalf("expected count, rate and ETA, got %q", got)
+	}
+
+	buf.Reset()
+	p.Error("upload failed")
+	ref, want := newTestOutput()
+	ref.SetColorEnabled(true)
+	ref.SetTheme(ThemeDracula)
+	ref.Error("upload failed")
+	if buf.String() != ansiClearLine+want.String() {
+		t.Fatalf("expected bar cleared and error line printed, got %q", buf.String())
+	}
+	if o.live != nil {
+		t.Fatal("expected bar to detach when finished")
+	}
+}
+
+func TestProgressRedrawThrottled(t *testing.T) {
+	now := fakeClock(t)
+	o, buf := newAnimatedTestOutput()
+
+	p := o.Progress("copying", 100)
+	before := strings.Count(buf.String(), ansiClearLine)
+	p.Add(1)
+	p.Add(1)
+	if strings.Count(buf.String(), ansiClearLine) != before {
+		t.Fatalf("expected redraws to be throttled, got %q", buf.String())
+	}
+	*now = now.Add(progressRedrawInterval)
+	p.Add(1)
+	if strings.Count(buf.String(), ansiClearLine) != before+1 {
+		t.Fatalf("expected a redraw after the interval, got %q", buf.String())
+	}
+	p.Add(97) // completion always redraws
+	if strings.Count(buf.String(), ansiClearLine) != before+2 {
+		t.Fatalf("expected a redraw on completion, got %q", buf.String())
+	}
+	p.Stop()
+}
+
+func TestProgressReaderAndWriter(t *testing.T) {
+	now := fakeClock(t)
+	o, buf := newTestOutput()
+
+	src := strings.NewReader(strings.Repeat("x", 3000))
+	p := o.Progress("copying", 6000)
+	var dst bytes.Buffer
+	*now = now.Add(3 * time.Second)
+	if _, err := io.Copy(p.Writer(&dst), p.Reader(src)); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Len() != 3000 {
+		t.Fatalf("expected 3000 bytes copied, got %d", dst.Len())
+	}
+	if p.current != 6000 {
+		t.Fatalf("expected reader and writer to both advance the bar, got %d", p.current)
+	}
+	if !strings.Contains(buf.String(), "50% (3.0 kB/6.0 kB, 1.0 kB/s, ETA 3s)") {
+		t.Fatalf("expected byte-formatted stats, got %q", buf.String())
+	}
+	p.Success("copied")
+}
+
+func TestProgressFinishOnlyOnce(t *testing.T) {
+	o, buf := newTestOutput()
+	p := o.Progress("working", 10)
+	p.Success("done")
+	p.Error("ignored")
+	p.Add(5)
+	if buf.String() != "» working\n» done\n" {
+		t.Fatalf("expected a single final line, got %q", buf.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{
+		0:             "0 B",
+		999:           "999 B",
+		1000:          "1.0 kB",
+		4500000:       "4.5 MB",
+		12000000000:   "12.0 GB",
+		1500000000000: "1.5 TB",
+	}
+	for n, want := range cases {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	cases := map[time.Duration]string{
+		0:                              "0s",
+		45 * time.Second:               "45s",
+		125 * time.Second:              "2m05s",
+		time.Hour + 2*time.Minute + 20: "1h02m",
+	}
+	for d, want := range cases {
+		if got := formatETA(d); got != want {
+			t.Errorf("formatETA(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+// --- Multi-progress tests ---
+
+func TestMultiProgressNonTTY(t *testing.T) {
+	o, buf := newTestOutput()
+	m := o.MultiProgress()
+	a := m.Spinner("building api")
+	b := m.Progress("pushing worker", 10)
+	a.Success("built api")
+	b.Error("push failed")
+	m.Wait()
+
+	want := "» building api\n» pushing worker\n» built api\n» push failed\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestMultiProgressRowsOnTerminal(t *testing.T) {
+	o, buf := newAnimatedTestOutput()
+	m := o.MultiProgress()
+	a := m.Spinner("building api")
+	b := m.Spinner("building worker")
+
+	if o.live == nil || len(o.live.rows) != 2 {
+		t.Fatal("expected both tasks to share one live area")
+	}
+	if !strings.Contains(buf.String(), "building api") || !strings.Contains(buf.String(), "\n") {
+		t.Fatalf("expected rows drawn on separate lines, got %q", buf.String())
+	}
+
+	buf.Reset()
+	a.Success("built api")
+	got := buf.String()
+	// Two rows are erased, the result line is printed, and the remaining row
+	// is redrawn below it.
+	erase := ansiClearLine + ansiLineUp + ansiClearLine
+	if !strings.HasPrefix(got, erase) {
+		t.Fatalf("expected both rows erased first, got %q", got)
+	}
+	ref, want := newTestOutput()
+	ref.SetColorEnabled(true)
+	ref.Success("built api")
+	rest := strings.TrimPrefix(got, erase)
+	if !strings.HasPrefix(rest, want.String()) {
+		t.Fatalf("expected themed result line after erase, got %q", rest)
+	}
+	if !strings.Contains(rest, "building worker") || strings.Contains(rest, "building api") {
+		t.Fatalf("expected only the unfinished row redrawn, got %q", rest)
+	}
+
+	b.Warn("worker slow")
+	if o.live == nil {
+		t.Fatal("expected the display to stay attached until released")
+	}
+	m.Wait()
+	if o.live != nil {
+		t.Fatal("expected the display to detach after Wait")
+	}
+}
+
+func TestMultiProgressLinesScrollAbove(t *testing.T) {
+	o, buf := newAnimatedTestOutput()
+	m := o.MultiProgress()
+	m.Spinner("one")
+	m.Spinner("two")
+
+	buf.Reset()
+	o.Info("log line")
+	got := buf.String()
+	erase := ansiClearLine + ansiLineUp + ansiClearLine
+	if !strings.HasPrefix(got, erase) {
+		t.Fatalf("expected rows erased before the log line, got %q", got)
+	}
+	rest := strings.TrimPrefix(got, erase)
+	idx := strings.Index(rest, "log line\n")
+	if idx < 0 {
+		t.Fatalf("expected log line, got %q", got)
+	}
+	redrawn := rest[idx+len("log line\n"):]
+	if !strings.Contains(redrawn, "one") || !strings.Contains(redrawn, "two") {
+		t.Fatalf("expected rows redrawn below the log line, got %q", redrawn)
+	}
+	m.Stop()
+}
+
+func TestMultiProgressStopErasesUnfinished(t *testing.T) {
+	o, buf := newAnimatedTestOutput()
+	m := o.MultiProgress()
+	s := m.Spinner("never finished")
+	m.Stop()
+	if o.live != nil {
+		t.Fatal("expected display detached after Stop")
+	}
+	buf.Reset()
+	s.Success("too late")
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output for a task erased by Stop, got %q", buf.String())
+	}
+}
+
+func TestMultiProgressWaitBlocksUntilDone(t *testing.T) {
+	o, _ := newAnimatedTestOutput()
+	m := o.MultiProgress()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		s := m.Spinner(fmt.Sprintf("task %d", i))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				s.Updatef("task %d step %d", i, j)
+				o.Debugf("task %d log %d", i, j)
+			}
+			s.Successf("task %d done", i)
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.Wait()
+		close(done)
+	}()
+	wg.Wait()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after all tasks finished")
+	}
+	if o.live != nil {
+		t.Fatal("expected display detached after Wait")
+	}
+}
+
+func TestStandaloneSpinnerJoinsMultiProgress(t *testing.T) {
+	o, _ := newAnimatedTestOutput()
+	m := o.MultiProgress()
+	m.Spinner("row")
+	s := o.Spinner("standalone")
+	if len(o.live.rows) != 2 {
+		t.Fatalf("expected standalone spinner to join the live area, got %d rows", len(o.live.rows))
+	}
+	s.Stop()
+	m.Stop()
+	if o.live != nil {
+		t.Fatal("expected display detached")
+	}
+}
+
+// --- Text measurement tests ---
+
+func TestVisibleWidthIgnoresANSI(t *testing.T) {
+	colored := ColorRed.apply("hello", true) + " " + Hex("#FF0000").apply("wörld", true)
+	if got := visibleWidth(colored); got != 11 {
+		t.Fatalf("expected visible width 11, got %d", got)
+	}
+	if got := stripANSI(colored); got != "hello wörld" {
+		t.Fatalf("expected stripped text, got %q", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("abcdef", 10); got != "abcdef" {
+		t.Fatalf("expected short text unchanged, got %q", got)
+	}
+	if got := truncate("abcdef", 4); got != "abc…" {
+		t.Fatalf("expected 'abc…', got %q", got)
+	}
+	got := truncate(ColorRed.apply("abcdef", true), 4)
+	if stripANSI(got) != "abc…" || !strings.HasSuffix(got, "\033[0m") {
+		t.Fatalf("expected colored truncation with reset, got %q", got)
+	}
+}
+
+func TestPad(t *testing.T) {
+	cases := []struct {
+		align Align
+		want  string
+	}{
+		{AlignLeft, "ab   "},
+		{AlignRight, "   ab"},
+		{AlignCenter, " ab  "},
+	}
+	for _, c := range cases {
+		if got := pad("ab", 5, c.align); got != c.want {
+			t.Errorf("pad(%v) = %q, want %q", c.align, got, c.want)
+		}
+	}
+}
+
+func TestTerminalWidthFromColumns(t *testing.T) {
+	t.Setenv("COLUMNS", "120")
+	if got := terminalWidth(); got != 120 {
+		t.Fatalf("expected 120, got %d", got)
+	}
+	t.Setenv("COLUMNS", "nonsense")
+	if got := terminalWidth(); got != 0 {
+		t.Fatalf("expected 0 for invalid COLUMNS, got %d", got)
+	}
+}
+
+func TestRuneWidth(t *testing.T) {
+	cases := map[rune]int{
+		'a': 1, 'é': 1, '»': 1, '─': 1, '\t': 0, 0x7F: 0,
+		'中': 2, 'ア': 2, '한': 2, 'Ａ': 2, '🚀': 2, '✅': 2, '😀': 2,
+		0x0301: 0, 0x200D: 0, 0xFE0F: 0,
+	}
+	for r, want := range cases {
+		if got := runeWidth(r); got != want {
+			t.Errorf("runeWidth(%U) = %d, want %d", r, got, want)
+		}
+	}
+}
+
+func TestVisibleWidthWideCharacters(t *testing.T) {
+	if got := visibleWidth(ColorRed.apply("中文", true) + " ok"); got != 7 {
+		t.Fatalf("expected width 7, got %d", got)
+	}
+	if got := visibleWidth("e\u0301"); got != 1 {
+		t.Fatalf("expected combining mark to take no space, got %d", got)
+	}
+	if got := truncate("中文字", 4); got != "中…" {
+		t.Fatalf("expected wide truncation '中…', got %q", got)
+	}
+	if got := pad("中", 4, AlignLeft); got != "中  " {
+		t.Fatalf("expected wide padding, got %q", got)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cases := []struct {
+		in    string
+		width int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"the quick brown fox jumps", 10, []string{"the quick", "brown fox", "jumps"}},
+		{"a  b", 2, []string{"a", "b"}},
+		{"abcdefghij klm", 4, []string{"abcd", "efgh", "ij", "klm"}},
+		{"中文中文 中文", 5, []string{"中文", "中文", "中文"}},
+		{"no wrap here", 0, []string{"no wrap here"}},
+		{"  indented text here", 10, []string{"  indented", "text here"}},
+	}
+	for _, c := range cases {
+		if got := wrap(c.in, c.width); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("wrap(%q, %d) = %q, want %q", c.in, c.width, got, c.want)
+		}
+	}
+}
+
+func TestWrapCarriesANSIAcrossLines(t *testing.T) {
+	in := "plain " + ColorRed.apply("red words here", true) + " tail"
+	got := wrap(in, 10)
+	want := []string{
+		"plain " + "\033[31mred" + ansiReset,
+		"\033[31mwords here" + ansiReset,
+		"tail",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	for _, line := range got {
+		if w := visibleWidth(line); w > 10 {
+			t.Errorf("line %q is %d columns wide", line, w)
+		}
+	}
+}
+
+func TestWrapOpensNextLineWithEscapesAtBreak(t *testing.T) {
+	got := wrap("aaaa bbbb "+ColorCyan.apply("cccc", true), 9)
+	want := []string{"aaaa bbbb", ColorCyan.apply("cccc", true)}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetWidth(30)
+	o.With(F("k", "a b")).Info("abc def ghi jkl mno pqr stu")
+	if strings.Contains(buf.String(), "\033[36m\033[0m") {
+		t.Fatalf("expected no empty colored run at a line end, got %q", buf.String())
+	}
+}
+
+func TestPrintWrapsWithHangingIndent(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetWidth(30)
+	o.Info("deploying the api gateway to the production cluster now")
+	want := "» deploying the api gateway to\n" +
+		"  the production cluster now\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestPrintWrapsFieldsAndGroups(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetWidth(31)
+	o.SetGroupStyle(GroupGuides)
+	g := o.Group("phase").With(F("service", "api-gateway"))
+	g.Info("rolling out the new release")
+	want := "» phase\n" +
+		"» │ rolling out the new release\n" +
+		"  │ service=api-gateway\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestPrintWrapDisabled(t *testing.T) {
+	msg := strings.Repeat("word ", 20)
+	o, buf := newTestOutput()
+	o.SetWidth(-1)
+	t.Setenv("COLUMNS", "30")
+	o.Info(msg)
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected a single line with wrapping disabled, got %q", buf.String())
+	}
+
+	o, buf = newTestOutput()
+	o.Info(msg)
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected COLUMNS to be ignored for non-terminal writers, got %q", buf.String())
+	}
+
+	o, buf = newTestOutput()
+	o.SetWidth(22) // 20 columns after the prefix: the minimum
+	o.SetPrefix(strings.Repeat(">", 10))
+	o.Info(msg)
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected no wrapping when little room is left, got %q", buf.String())
+	}
+}
+
+func TestPrintWrapsColoredMessagesPerLine(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetWidth(24)
+	o.Warn("disk usage is above the configured threshold")
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if !strings.HasSuffix(line, ansiReset) {
+			t.Errorf("expected each line to end with a reset, got %q", line)
+		}
+		if w := visibleWidth(line); w > 24 {
+			t.Errorf("line %q is %d columns wide", line, w)
+		}
+	}
+}
+
+func TestWindowWidthOfNonTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "notty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got := windowWidth(f.Fd()); got != 0 {
+		t.Fatalf("expected 0 for a regular file, got %d", got)
+	}
+	t.Setenv("COLUMNS", "77")
+	if got := writerWidth(f); got != 0 {
+		t.Fatalf("expected COLUMNS to be ignored for a regular file, got %d", got)
+	}
+	if got := writerWidth(&bytes.Buffer{}); got != 0 {
+		t.Fatalf("expected COLUMNS to be ignored for a buffer, got %d", got)
+	}
+}
+
+func TestTableUsesOutputWidth(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetWidth(16)
+	tbl := o.Table("NAME", "DESCRIPTION")
+	tbl.AddRow("api", "a very long description")
+	tbl.Render()
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if w := visibleWidth(line); w > 16 {
+			t.Errorf("line %q is %d columns wide", line, w)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	if got := splitLines("one"); fmt.Sprint(got) != "[one]" {
+		t.Fatalf("expected a single line, got %q", got)
+	}
+	if got := splitLines("a\r\nb\n"); fmt.Sprint(got) != fmt.Sprint([]string{"a", "b", ""}) {
+		t.Fatalf("expected CRs dropped, got %q", got)
+	}
+	got := splitLines("x " + ColorRed.apply("red\nstill red", true) + " y")
+	want := []string{"x \033[31mred" + ansiReset, "\033[31mstill red\033[0m y"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+// --- Multi-line message tests ---
+
+func TestMultilineMessageAligned(t *testing.T) {
+	o, buf := newTestOutput()
+	o.Info("first line\nsecond line\n\nfourth line")
+	want := "» first line\n" +
+		"  second line\n" +
+		"\n" +
+		"  fourth line\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%q\ngot:\n%q", want, buf.String())
+	}
+}
+
+func TestMultilineMessagePrefixed(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetMultilineStyle(MultilinePrefix)
+	o.Info("first\nsecond\n\nfourth")
+	want := "» first\n» second\n»\n» fourth\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestMultilineMessageColoredPerLine(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.Error("failed\ndetails")
+	want := ThemeDefault.PrefixColor.apply("»", true) + " " + ThemeDefault.ErrorColor.apply("failed", true) + "\n" +
+		"  " + ThemeDefault.ErrorColor.apply("details", true) + "\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestMultilineMessageFieldsAndGroups(t *testing.T) {
+	o, buf := newTestOutput()
+	o.Group("phase").With(F("k", "v")).Info("a\nb")
+	want := "» phase\n»   a\n    b k=v\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestMultilineMessageWrapsEachLine(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetWidth(24)
+	o.SetMultilineStyle(MultilinePrefix)
+	o.Info("short\nthis second line is long enough to wrap")
+	want := "» short\n" +
+		"» this second line is\n" +
+		"  long enough to wrap\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestMultilineMessageJSONUnchanged(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetFormat(FormatJSON)
+	o.Info("a\nb")
+	if !strings.Contains(buf.String(), `"msg":"a\nb"`) {
+		t.Fatalf("expected the message intact, got %q", buf.String())
+	}
+}
+
+func TestPackageLevelSetMultilineStyle(t *testing.T) {
+	buf, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	SetMultilineStyle(MultilinePrefix)
+	Info("a\nb")
+	if buf.String() != "» a\n» b\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+// --- Table tests ---
+
+func TestTableNoBorder(t *testing.T) {
+	o, buf := newTestOutput()
+	tbl := o.Table("NAME", "STATUS", "VERSION")
+	tbl.SetAlign(2, AlignRight)
+	tbl.SetMaxWidth(0)
+	tbl.AddRow("api-gateway", "healthy", "1.2.3")
+	tbl.AddRow("worker", "degraded", "10.0.0")
+	tbl.Render()
+
+	want := "» NAME         STATUS    VERSION\n" +
+		"» api-gateway  healthy     1.2.3\n" +
+		"» worker       degraded   10.0.0\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestTableASCIIBorder(t *testing.T) {
+	o, buf := newTestOutput()
+	o.ClearPrefix()
+	tbl := o.Table("A", "B")
+	tbl.SetBorder(BorderASCII)
+	tbl.SetAlign(1, AlignCenter)
+	tbl.SetMaxWidth(0)
+	tbl.AddRow("one", "x")
+	tbl.Render()
+
+	want := "+-----+---+\n" +
+		"| A   | B |\n" +
+		"+-----+---+\n" +
+		"| one | x |\n" +
+		"+-----+---+\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestTableUnicodeBorder(t *testing.T) {
+	o, buf := newTestOutput()
+	o.ClearPrefix()
+	tbl := o.Table("K", "V")
+	tbl.SetBorder(BorderUnicode)
+	tbl.SetMaxWidth(0)
+	tbl.AddRow("a", "1")
+	tbl.Render()
+
+	want := "┌───┬───┐\n" +
+		"│ K │ V │\n" +
+		"├───┼───┤\n" +
+		"│ a │ 1 │\n" +
+		"└───┴───┘\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestTableAlignsColorizedCells(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.ClearPrefix()
+	tbl := o.Table("SVC", "STATE")
+	tbl.SetMaxWidth(0)
+	tbl.AddRow("api", o.Colorize("ok", ColorGreen))
+	tbl.AddRow("auth", "failing")
+	tbl.Render()
+
+	lines := strings.Split(strings.TrimSuffix(stripANSI(buf.String()), "\n"), "\n")
+	want := []string{"SVC   STATE", "api   ok", "auth  failing"}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d: expected %q, got %q", i, w, lines[i])
+		}
+	}
+}
+
+func TestTableThemedColors(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetTheme(ThemeDracula)
+	tbl := o.Table("NAME", "STATUS")
+	tbl.SetBorder(BorderUnicode)
+	tbl.SetMaxWidth(0)
+	tbl.AddRow("api", "up")
+	tbl.AddRow("db", "down")
+	tbl.SetColumnColor(1, ColorGreen)
+	tbl.SetCellColor(1, 1, ColorRed)
+	tbl.Render()
+
+	got := buf.String()
+	if !strings.Contains(got, ThemeDracula.PrefixColor.apply("NAME", true)) {
+		t.Errorf("expected header in prefix color, got %q", got)
+	}
+	if !strings.Contains(got, ThemeDracula.DebugColor.apply("│", true)) {
+		t.Errorf("expected border in debug color, got %q", got)
+	}
+	if !strings.Contains(got, ThemeDracula.InfoColor.apply("api", true)) {
+		t.Errorf("expected cell in info color, got %q", got)
+	}
+	if !strings.Contains(got, ColorGreen.apply("up", true)) {
+		t.Errorf("expected column color, got %q", got)
+	}
+	if !strings.Contains(got, ColorRed.apply("down", true)) {
+		t.Errorf("expected cell color to override column color, got %q", got)
+	}
+}
+
+func TestTableTruncatesToMaxWidth(t *testing.T) {
+	o, buf := newTestOutput()
+	tbl := o.Table("ID", "DESCRIPTION")
+	tbl.SetMaxWidth(20)
+	tbl.AddRow("1", "a very long description that will not fit")
+	tbl.Render()
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if w := visibleWidth(line); w > 20 {
+			t.Errorf("line exceeds max width (%d): %q", w, line)
+		}
+	}
+	if !strings.Contains(buf.String(), "» 1   a very long d…") {
+		t.Fatalf("expected truncated description, got %q", buf.String())
+	}
+}
+
+func TestTableIgnoresColumnsWhenNotATerminal(t *testing.T) {
+	t.Setenv("COLUMNS", "16")
+	o, buf := newTestOutput()
+	tbl := o.Table("NAME")
+	tbl.AddRow("abcdefghijklmnopqrstuvwxyz")
+	tbl.Render()
+	if !strings.Contains(buf.String(), "» abcdefghijklmnopqrstuvwxyz\n") {
+		t.Fatalf("expected no truncation to COLUMNS, got %q", buf.String())
+	}
+}
+
+func TestTableRespectsLevel(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetLevel(LevelWarn)
+	tbl := o.Table("A")
+	tbl.AddRow("x")
+	tbl.Render()
+	if buf.Len() != 0 {
+		t.Fatalf("expected table suppressed at warn level, got %q", buf.String())
+	}
+}
+
+func TestTableRaggedRows(t *testing.T) {
+	o, buf := newTestOutput()
+	o.ClearPrefix()
+	tbl := o.Table("A", "B")
+	tbl.SetMaxWidth(0)
+	tbl.AddRow("1")
+	tbl.AddRow("2", "3", "4")
+	tbl.Render()
+	want := "A  B\n1\n2  3  4\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+// --- Tree tests ---
+
+func testTree() *TreeNode {
+	return Node("myapp",
+		Node("github.com/a/lib",
+			Node("github.com/b/util"),
+			Node("github.com/c/log"),
+		),
+		Node("github.com/d/cli"),
+	)
+}
+
+func TestTreeUnicode(t *testing.T) {
+	o, buf := newTestOutput()
+	o.Tree(testTree())
+
+	want := "» myapp\n" +
+		"» ├── github.com/a/lib\n" +
+		"» │   ├── github.com/b/util\n" +
+		"» │   └── github.com/c/log\n" +
+		"» └── github.com/d/cli\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestTreeASCII(t *testing.T) {
+	o, buf := newTestOutput()
+	o.ClearPrefix()
+	root := Node("root")
+	a := root.Add("a")
+	a.Add("a1")
+	root.Add("b").Add("b1")
+	o.TreeWithStyle(root, TreeASCII)
+
+	want := "root\n" +
+		"|-- a\n" +
+		"|   `-- a1\n" +
+		"`-- b\n" +
+		"    `-- b1\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestTreeLevelFiltering(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetLevel(LevelInfo)
+	root := testTree()
+	debug := Node("debug only", Node("hidden child"))
+	debug.Level = LevelDebug
+	root.Children = append(root.Children, debug)
+	o.Tree(root)
+
+	got := buf.String()
+	if strings.Contains(got, "debug only") || strings.Contains(got, "hidden child") {
+		t.Fatalf("expected debug subtree hidden, got %q", got)
+	}
+	if !strings.Contains(got, "» └── github.com/d/cli\n") {
+		t.Fatalf("expected last visible child to use the last-branch glyph, got %q", got)
+	}
+
+	buf.Reset()
+	o.SetLevel(LevelWarn)
+	o.Tree(testTree())
+	if buf.Len() != 0 {
+		t.Fatalf("expected info tree hidden at warn level, got %q", buf.String())
+	}
+}
+
+func TestTreeLiteralNodesDefaultToInfo(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetLevel(LevelInfo)
+	root := &TreeNode{Text: "root", Children: []*TreeNode{
+		{Text: "child"},
+		(&TreeNode{Text: "trace only"}).SetLevel(LevelTrace),
+	}}
+	o.Tree(root)
+	if want := "» root\n» └── child\n"; buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+
+	buf.Reset()
+	o.SetLevel(LevelTrace)
+	o.Tree(root)
+	if !strings.Contains(buf.String(), "» └── trace only\n") {
+		t.Fatalf("expected trace node at trace level, got %q", buf.String())
+	}
+}
+
+func TestTreeColors(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetTheme(ThemeDracula)
+	root := Node("root")
+	root.Add("ok")
+	bad := root.Add("bad")
+	bad.Level = LevelError
+	o.Tree(root)
+
+	got := buf.String()
+	if !strings.Contains(got, ThemeDracula.PrefixColor.apply("├── ", true)) {
+		t.Errorf("expected branch in prefix color, got %q", got)
+	}
+	if !strings.Contains(got, ThemeDracula.InfoColor.apply("ok", true)) {
+		t.Errorf("expected info node in info color, got %q", got)
+	}
+	if !strings.Contains(got, ThemeDracula.ErrorColor.apply("bad", true)) {
+		t.Errorf("expected error node in error color, got %q", got)
+	}
+
+	buf.Reset()
+	o.SetColorEnabled(false)
+	o.Tree(root)
+	if strings.Contains(buf.String(), "\033[") {
+		t.Errorf("expected no ANSI codes with color disabled, got %q", buf.String())
+	}
+}
+
+func TestTreeNil(t *testing.T) {
+	o, buf := newTestOutput()
+	o.Tree(nil)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output for nil tree, got %q", buf.String())
+	}
+}
+
+// --- Structured field tests ---
+
+func TestWithAppendsFields(t *testing.T) {
+	o, buf := newTestOutput()
+	o.With(F("service", "api"), F("replicas", 3)).Info("deployed")
+	if got := buf.String(); got != "» deployed service=api replicas=3\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestWithNestedChildren(t *testing.T) {
+	o, buf := newTestOutput()
+	svc := o.With(F("service", "api"))
+	svc.With(F("attempt", 2)).Warn("retrying")
+	svc.Info("ok")
+	o.Info("parent unchanged")
+
+	want := "» retrying service=api attempt=2\n» ok service=api\n» parent unchanged\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWithSharesConfiguration(t *testing.T) {
+	o, buf := newTestOutput()
+	child := o.With(F("k", "v"))
+	o.SetLevel(LevelWarn)
+	child.Info("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("expected child to follow parent's level, got %q", buf.String())
+	}
+	child.SetPrefix(">>")
+	o.Warn("shared prefix")
+	if buf.String() != ">> shared prefix\n" {
+		t.Fatalf("expected prefix set on child to apply to parent, got %q", buf.String())
+	}
+}
+
+func TestFieldQuoting(t *testing.T) {
+	cases := []struct {
+		value any
+		want  string
+	}{
+		{"simple", "simple"},
+		{"", `""`},
+		{"two words", `"two words"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"a=b", `"a=b"`},
+		{"tab\there", `"tab\there"`},
+		{42, "42"},
+		{true, "true"},
+		{nil, "<nil>"},
+		{fmt.Errorf("connection refused"), `"connection refused"`},
+		{time.Second, "1s"},
+	}
+	for _, c := range cases {
+		if got := quoteIfNeeded(formatValue(c.value)); got != c.want {
+			t.Errorf("value %#v: expected %s, got %s", c.value, c.want, got)
+		}
+	}
+}
+
+func TestFieldColors(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetTheme(ThemeDracula)
+	o.With(F("env", "prod")).Info("deploying")
+
+	want := ThemeDracula.PrefixColor.apply("»", true) + " " +
+		ThemeDracula.InfoColor.apply("deploying", true) + " " +
+		ThemeDracula.PrefixColor.apply("env", true) + "=" +
+		ThemeDracula.DebugColor.apply("prod", true) + "\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWithFieldsOnSpinnerResult(t *testing.T) {
+	o, buf := newTestOutput()
+	o.With(F("image", "api:v1")).Spinner("building").Success("built")
+	want := "» building image=api:v1\n» built image=api:v1\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestPackageLevelWith(t *testing.T) {
+	buf, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	With(F("user", "alice")).Success("logged in")
+	if buf.String() != "» logged in user=alice\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+// --- JSON format tests ---
+
+func TestJSONFormatLine(t *testing.T) {
+	fakeClock(t)
+	o, buf := newTestOutput()
+	o.SetFormat(FormatJSON)
+	o.With(F("service", "api"), F("replicas", 3), F("err", fmt.Errorf("boom"))).Warn("slow <start>")
+
+	want := `{"time":"2024-01-01T00:00:00Z","level":"warn","msg":"slow <start>","success":false,` +
+		`"fields":{"service":"api","replicas":3,"err":"boom"}}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("expected %s, got %s", want, buf.String())
+	}
+}
+
+func TestJSONFormatSuccessAndLevels(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetFormat(FormatJSON)
+	o.Success("done")
+	o.Debug("details")
+	o.exitFunc = func(int) {}
+	o.Fatal("fatal")
+
+	type entry struct {
+		Level   string         `json:"level"`
+		Msg     string         `json:"msg"`
+		Success bool           `json:"success"`
+		Fields  map[string]any `json:"fields"`
+	}
+	var got []entry
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var e entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid JSON %q: %v", line, err)
+		}
+		got = append(got, e)
+	}
+	want := []entry{
+		{Level: "info", Msg: "done", Success: true},
+		{Level: "debug", Msg: "details"},
+		{Level: "error", Msg: "fatal"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Level != want[i].Level || got[i].Msg != want[i].Msg || got[i].Success != want[i].Success || got[i].Fields != nil {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestJSONFormatStripsColorAndPrefix(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetFormat(FormatJSON)
+	o.Infof("status %s", o.Colorize("ok", ColorGreen))
+	if strings.Contains(buf.String(), "\033[") || strings.Contains(buf.String(), "»") {
+		t.Fatalf("expected no ANSI codes or prefix in JSON output, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"msg":"status ok"`) {
+		t.Fatalf("expected plain message, got %q", buf.String())
+	}
+}
+
+func TestJSONFormatDuplicateAndUnencodableFields(t *testing.T) {
+	fakeClock(t)
+	line := jsonLine(LevelInfo, "m", false, []Field{F("a", 1), F("b", make(chan int)), F("a", 2)})
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %v", line, err)
+	}
+	fields := decoded["fields"].(map[string]any)
+	if fields["a"] != float64(2) {
+		t.Errorf("expected last value to win for duplicate key, got %v", fields["a"])
+	}
+	if _, ok := fields["b"].(string); !ok {
+		t.Errorf("expected unencodable value rendered as string, got %v", fields["b"])
+	}
+	if !strings.HasSuffix(line, `,"a":2}}`) {
+		t.Errorf("expected only the last occurrence of a repeated key, got %s", line)
+	}
+}
+
+func TestJSONFormatSpinnerDoesNotAnimate(t *testing.T) {
+	o, buf := newAnimatedTestOutput()
+	o.SetFormat(FormatJSON)
+	o.Spinner("working").Success("done")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || strings.Contains(buf.String(), "\r") {
+		t.Fatalf("expected two JSON lines and no redraws, got %q", buf.String())
+	}
+}
+
+func TestJSONFormatTableRows(t *testing.T) {
+	fakeClock(t)
+	o, buf := newTestOutput()
+	o.SetFormat(FormatJSON)
+	tbl := o.Table("NAME", "STATUS")
+	tbl.AddRow("api", "up", "extra")
+	tbl.Render()
+	want := `{"time":"2024-01-01T00:00:00Z","level":"info","msg":"","success":false,` +
+		`"fields":{"NAME":"api","STATUS":"up","column3":"extra"}}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("expected %s, got %s", want, buf.String())
+	}
+}
+
+func TestJSONFormatTreeNodes(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetFormat(FormatJSON)
+	root := Node("root")
+	warn := root.Add("child")
+	warn.Level = LevelWarn
+	o.Tree(root)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	if !strings.Contains(lines[1], `"level":"warn","msg":"child"`) || !strings.Contains(lines[1], `"depth":1`) {
+		t.Fatalf("expected child node with level and depth, got %s", lines[1])
+	}
+}
+
+func TestJSONFormatKeepsWithFields(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetFormat(FormatJSON)
+	child := o.With(F("req", 7))
+	tbl := child.Table("NAME")
+	tbl.AddRow("api")
+	tbl.Render()
+	child.Group("phase")
+	child.Tree(Node("root"))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{`"fields":{"req":7,"NAME":"api"}`, `"fields":{"req":7}`, `"fields":{"req":7,"depth":0}`}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %q", len(want), buf.String())
+	}
+	for i, w := range want {
+		if !strings.Contains(lines[i], w) {
+			t.Errorf("line %d: expected %s, got %s", i, w, lines[i])
+		}
+	}
+}
+
+func TestNewRespectsCliFormatEnv(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "JSON")
+	if o := New(); o.format != FormatJSON {
+		t.Fatalf("expected JSON format, got %v", o.format)
+	}
+	t.Setenv("CLI_FORMAT", "bogus")
+	if o := New(); o.format != FormatText {
+		t.Fatalf("expected text format for unknown value, got %v", o.format)
+	}
+}
+
+func TestFormatString(t *testing.T) {
+	if FormatText.String() != "text" || FormatJSON.String() != "json" || Format(9).String() != "unknown" {
+		t.Fatal("unexpected Format.String values")
+	}
+}
+
+func TestPackageLevelSetFormat(t *testing.T) {
+	buf, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	SetFormat(FormatJSON)
+	Info("hello")
+	if !strings.HasPrefix(buf.String(), `{"time":`) {
+		t.Fatalf("expected JSON output, got %q", buf.String())
+	}
+}
+
+// --- Prompt tests ---
+
+func TestConfirmAnswers(t *testing.T) {
+	tests := []struct {
+		input string
+		def   bool
+		want  bool
+	}{
+		{"y\n", false, true},
+		{"YES\n", false, true},
+		{"n\n", true, false},
+		{"No\r\n", true, false},
+		{"\n", true, true},
+		{"\n", false, false},
+		{"  y  \n", false, true},
+		{"y", false, true}, // no trailing newline
+	}
+	for _, tt := range tests {
+		o, _ := newTestOutput()
+		o.SetReader(strings.NewReader(tt.input))
+		got, err := o.Confirm("Continue?", tt.def)
+		if err != nil {
+			t.Fatalf("input %q: unexpected error %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("input %q, default %v: expected %v, got %v", tt.input, tt.def, tt.want, got)
+		}
+	}
+}
+
+func TestConfirmRendersPromptAndHint(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("y\n"))
+	if _, err := o.Confirm("Delete everything?", false); err != nil {
+		t.Fatal(err)
+	}
+	want := "» Delete everything? [y/N] y\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+
+	o, buf = newTestOutput()
+	o.SetReader(strings.NewReader("\n"))
+	_, _ = o.Confirm("Proceed?", true)
+	if !strings.Contains(buf.String(), "[Y/n]") {
+		t.Fatalf("expected default-yes hint, got %q", buf.String())
+	}
+}
+
+func TestConfirmUsesWarnColorAndDestination(t *testing.T) {
+	o, infoBuf := newTestOutput()
+	var warnBuf bytes.Buffer
+	o.SetLevelWriter(LevelWarn, &warnBuf)
+	o.SetColorEnabled(true)
+	o.SetReader(strings.NewReader("n\n"))
+	_, _ = o.Confirm("Sure?", false)
+	if infoBuf.Len() != 0 {
+		t.Fatalf("expected nothing on the info destination, got %q", infoBuf.String())
+	}
+	if !strings.Contains(warnBuf.String(), ThemeDefault.WarnColor.apply("Sure?", true)) {
+		t.Fatalf("expected question in WarnColor, got %q", warnBuf.String())
+	}
+}
+
+func TestConfirmRepeatsOnInvalidInput(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("maybe\nyes\n"))
+	got, err := o.Confirm("Continue?", false)
+	if err != nil || !got {
+		t.Fatalf("expected true, nil; got %v, %v", got, err)
+	}
+	if strings.Count(buf.String(), "Continue?") != 2 {
+		t.Fatalf("expected the question twice, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "please answer y or n") {
+		t.Fatalf("expected a validation message, got %q", buf.String())
+	}
+}
+
+func TestConfirmEOFReturnsDefault(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader(""))
+	got, err := o.Confirm("Continue?", true)
+	if !errors.Is(err, io.EOF) || !got {
+		t.Fatalf("expected true, io.EOF; got %v, %v", got, err)
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Fatalf("expected the prompt line to be terminated, got %q", buf.String())
+	}
+}
+
+func TestConfirmNonTerminalFileFailsFast(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("y\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	o, buf := newTestOutput()
+	o.SetReader(f)
+	got, err := o.Confirm("Continue?", true)
+	if !errors.Is(err, ErrNotInteractive) || !got {
+		t.Fatalf("expected true, ErrNotInteractive; got %v, %v", got, err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no prompt, got %q", buf.String())
+	}
+}
+
+func TestConfirmReadsSuccessiveAnswers(t *testing.T) {
+	o, _ := newTestOutput()
+	o.SetReader(strings.NewReader("y\nn\n"))
+	first, _ := o.Confirm("First?", false)
+	second, _ := o.Confirm("Second?", true)
+	if !first || second {
+		t.Fatalf("expected true then false, got %v then %v", first, second)
+	}
+}
+
+// slowReader waits before each read, giving animations time to run while a
+// prompt waits for its answer.
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.r.Read(p)
+}
+
+func TestConfirmPausesLiveArea(t *testing.T) {
+	orig := spinnerInterval
+	spinnerInterval = time.Millisecond
+	defer func() { spinnerInterval = orig }()
+
+	o, buf := newAnimatedTestOutput()
+	o.SetReader(slowReader{strings.NewReader("y\n"), 20 * time.Millisecond})
+	s := o.Spinner("working")
+	if _, err := o.Confirm("Sure?", false); err != nil {
+		t.Fatal(err)
+	}
+	o.mu.Lock()
+	drawn := o.live.drawn
+	o.mu.Unlock()
+	s.Stop()
+
+	if drawn != 1 {
+		t.Fatalf("expected the spinner to be redrawn after the prompt, got %d lines drawn", drawn)
+	}
+	got := buf.String()
+	start := strings.Index(got, "Sure?")
+	end := strings.Index(got, "y\n")
+	if start < 0 || end < start {
+		t.Fatalf("expected prompt and echoed answer, got %q", got)
+	}
+	if !strings.Contains(got[:start], "working"+ansiClearLine) {
+		t.Fatalf("expected spinner erased before the prompt, got %q", got[:start])
+	}
+	if during := got[start:end]; strings.Contains(during, ansiClearLine) || strings.ContainsAny(during, strings.Join(spinnerFrames, "")) {
+		t.Fatalf("expected no redraws while the prompt waits, got %q", during)
+	}
+	if !strings.ContainsAny(got[end:], strings.Join(spinnerFrames, "")) {
+		t.Fatalf("expected spinner redrawn after the answer, got %q", got[end:])
+	}
+}
+
+func TestPackageLevelConfirm(t *testing.T) {
+	buf, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	Default().SetReader(strings.NewReader("yes\n"))
+	got, err := Confirm("Continue?", false)
+	if err != nil || !got {
+		t.Fatalf("expected true, nil; got %v, %v", got, err)
+	}
+	if !strings.Contains(buf.String(), "Continue?") {
+		t.Fatalf("expected prompt on the default output, got %q", buf.String())
+	}
+}
+
+// --- Selection menu tests ---
+
+func TestSelectNumberedFallback(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("2\n"))
+	got, err := o.Select("Pick a colour", []string{"red", "green", "blue"})
+	if err != nil || got != 1 {
+		t.Fatalf("expected 1, nil; got %d, %v", got, err)
+	}
+	want := "» Pick a colour\n" +
+		"    1) red\n" +
+		"    2) green\n" +
+		"    3) blue\n" +
+		"» Choice [1-3] 2\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestSelectNumberedFallbackRepeatsOnInvalidInput(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("0\nfour\n1 2\n3\n"))
+	got, err := o.Select("Pick", []string{"a", "b", "c"})
+	if err != nil || got != 2 {
+		t.Fatalf("expected 2, nil; got %d, %v", got, err)
+	}
+	if n := strings.Count(buf.String(), "please enter a number between 1 and 3"); n != 3 {
+		t.Fatalf("expected 3 validation messages, got %d in %q", n, buf.String())
+	}
+}
+
+func TestSelectNumberedFallbackEOF(t *testing.T) {
+	o, _ := newTestOutput()
+	o.SetReader(strings.NewReader(""))
+	got, err := o.Select("Pick", []string{"a"})
+	if !errors.Is(err, io.EOF) || got != -1 {
+		t.Fatalf("expected -1, io.EOF; got %d, %v", got, err)
+	}
+}
+
+func TestMultiSelectNumberedFallback(t *testing.T) {
+	o, _ := newTestOutput()
+	o.SetReader(strings.NewReader("3, 1 3\n"))
+	got, err := o.MultiSelect("Pick", []string{"a", "b", "c"})
+	if err != nil || fmt.Sprint(got) != "[0 2]" {
+		t.Fatalf("expected [0 2], nil; got %v, %v", got, err)
+	}
+
+	o.SetReader(strings.NewReader("\n"))
+	got, err = o.MultiSelect("Pick", []string{"a", "b"})
+	if err != nil || len(got) != 0 {
+		t.Fatalf("expected no choices, got %v, %v", got, err)
+	}
+}
+
+func TestSelectNonTerminalFileFailsFast(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("2\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	o, buf := newTestOutput()
+	o.SetReader(f)
+	if got, err := o.Select("Pick", []string{"a", "b"}); !errors.Is(err, ErrNotInteractive) || got != -1 {
+		t.Fatalf("expected -1, ErrNotInteractive; got %v, %v", got, err)
+	}
+	if got, err := o.MultiSelect("Pick", []string{"a", "b"}); !errors.Is(err, ErrNotInteractive) || got != nil {
+		t.Fatalf("expected nil, ErrNotInteractive; got %v, %v", got, err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no prompt, got %q", buf.String())
+	}
+}
+
+func TestSelectNoOptions(t *testing.T) {
+	o, _ := newTestOutput()
+	if _, err := o.Select("Pick", nil); err == nil {
+		t.Fatal("expected an error for no options")
+	}
+	if _, err := o.MultiSelect("Pick", []string{}); err == nil {
+		t.Fatal("expected an error for no options")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("\033[A\033[Bx\r\x7f\033\x03\x04\x10\x0eé\033[5~\x01"))
+	want := []key{
+		{kind: keyUp}, {kind: keyDown}, {kind: keyRune, r: 'x'}, {kind: keyEnter},
+		{kind: keyBackspace}, {kind: keyEscape}, {kind: keyInterrupt}, {kind: keyEOF},
+		{kind: keyUp}, {kind: keyDown}, {kind: keyRune, r: 'é'},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := parseKeys([]byte("\033OA\033OB")); fmt.Sprint(got) != fmt.Sprint([]key{{kind: keyUp}, {kind: keyDown}}) {
+		t.Fatalf("expected SS3 arrows to be recognised, got %v", got)
+	}
+}
+
+func TestMenuNavigationWraps(t *testing.T) {
+	m := newMenu([]string{"a", "b", "c"}, false)
+	_, _ = m.handle(key{kind: keyUp})
+	if m.cursor != 2 {
+		t.Fatalf("expected cursor to wrap to 2, got %d", m.cursor)
+	}
+	_, _ = m.handle(key{kind: keyDown})
+	if m.cursor != 0 {
+		t.Fatalf("expected cursor to wrap to 0, got %d", m.cursor)
+	}
+}
+
+func TestMenuScrollsWithCursor(t *testing.T) {
+	options := make([]string, selectPageSize+5)
+	for i := range options {
+		options[i] = fmt.Sprintf("opt%d", i)
+	}
+	m := newMenu(options, false)
+	for i := 0; i < selectPageSize; i++ {
+		_, _ = m.handle(key{kind: keyDown})
+	}
+	if m.offset != 1 {
+		t.Fatalf("expected offset 1, got %d", m.offset)
+	}
+	_, _ = m.handle(key{kind: keyUp})
+	_, _ = m.handle(key{kind: keyUp})
+	for m.cursor > 0 {
+		_, _ = m.handle(key{kind: keyUp})
+	}
+	if m.offset != 0 {
+		t.Fatalf("expected offset 0, got %d", m.offset)
+	}
+}
+
+func TestMenuFilter(t *testing.T) {
+	m := newMenu([]string{"Apple", "banana", "grape"}, false)
+	for _, r := range "AP" {
+		_, _ = m.handle(key{kind: keyRune, r: r})
+	}
+	if fmt.Sprint(m.matches) != "[0 2]" {
+		t.Fatalf("expected case-insensitive matches [0 2], got %v", m.matches)
+	}
+	_, _ = m.handle(key{kind: keyRune, r: 'z'})
+	if done, _ := m.handle(key{kind: keyEnter}); done {
+		t.Fatal("expected Enter to be ignored with no matches")
+	}
+	_, _ = m.handle(key{kind: keyBackspace})
+	_, _ = m.handle(key{kind: keyDown})
+	if done, _ := m.handle(key{kind: keyEnter}); !done || fmt.Sprint(m.result()) != "[2]" {
+		t.Fatalf("expected grape to be chosen, got %v", m.result())
+	}
+	_, _ = m.handle(key{kind: keyEscape})
+	if len(m.filter) != 0 || len(m.matches) != 3 {
+		t.Fatalf("expected Esc to clear the filter, got %q", string(m.filter))
+	}
+}
+
+func TestMenuMultiToggle(t *testing.T) {
+	m := newMenu([]string{"a", "b", "c"}, true)
+	_, _ = m.handle(key{kind: keyRune, r: ' '})
+	_, _ = m.handle(key{kind: keyUp})
+	_, _ = m.handle(key{kind: keyRune, r: ' '})
+	_, _ = m.handle(key{kind: keyDown})
+	_, _ = m.handle(key{kind: keyDown})
+	_, _ = m.handle(key{kind: keyRune, r: ' '})
+	_, _ = m.handle(key{kind: keyRune, r: ' '})
+	if len(m.filter) != 0 {
+		t.Fatalf("expected space to toggle rather than filter, got %q", string(m.filter))
+	}
+	if done, _ := m.handle(key{kind: keyEnter}); !done || fmt.Sprint(m.result()) != "[0 2]" {
+		t.Fatalf("expected [0 2], got %v", m.result())
+	}
+}
+
+func TestMenuInterruptAndEOF(t *testing.T) {
+	m := newMenu([]string{"a"}, false)
+	if _, err := m.handle(key{kind: keyInterrupt}); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	if _, err := m.handle(key{kind: keyEOF}); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestRunMenuRendersAndChooses(t *testing.T) {
+	o, buf := newTestOutput()
+	got, err := o.runMenu(strings.NewReader("\033[B\r"), "Pick", newMenu([]string{"red", "green"}, false))
+	if err != nil || fmt.Sprint(got) != "[1]" {
+		t.Fatalf("expected [1], nil; got %v, %v", got, err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "  ❯ red\n    green") {
+		t.Fatalf("expected initial menu with cursor on red, got %q", out)
+	}
+	if !strings.HasSuffix(out, eraseLines(3)+"» Pick green\n"+ansiShowCursor) {
+		t.Fatalf("expected menu to collapse to a summary line, got %q", out)
+	}
+}
+
+func TestRunMenuTruncatesLinesToWidth(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetWidth(20)
+	options := []string{"short", strings.Repeat("a very long option ", 4)}
+	if _, err := o.runMenu(strings.NewReader("\033[B\033[A\r"), "Pick", newMenu(options, true)); err != nil {
+		t.Fatal(err)
+	}
+	out := strings.TrimSuffix(buf.String(), "\n"+ansiShowCursor)
+	for _, line := range strings.Split(out, "\n") {
+		for _, part := range strings.Split(line, ansiClearLine) {
+			if w := visibleWidth(part); w > 20 {
+				t.Fatalf("expected menu lines of at most 20 columns, got %d in %q", w, part)
+			}
+		}
+	}
+}
+
+func TestRunMenuHighlightsCursorInPrefixColor(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	_, _ = o.runMenu(strings.NewReader("\r"), "Pick", newMenu([]string{"red", "green"}, false))
+	if !strings.Contains(buf.String(), ThemeDefault.PrefixColor.apply("red", true)) {
+		t.Fatalf("expected cursor row in PrefixColor, got %q", buf.String())
+	}
+}
+
+func TestRunMenuEndOfInput(t *testing.T) {
+	o, buf := newTestOutput()
+	if _, err := o.runMenu(strings.NewReader("\033[B"), "Pick", newMenu([]string{"a", "b"}, false)); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if !strings.Contains(buf.String(), eraseLines(3)+"» Pick (↑/↓ to move, type to filter)\n    a\n  ❯ b") {
+		t.Fatalf("expected a redraw with the cursor on b, got %q", buf.String())
+	}
+}
+
+func TestMakeRawRejectsNonTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "notty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if restore, err := makeRaw(f.Fd()); err == nil {
+		restore()
+		t.Fatal("expected an error for a regular file")
+	}
+}
+
+// --- Input and password tests ---
+
+func TestInputReadsTrimmedAnswer(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("  my-app  \n"))
+	got, err := o.Input("Project name", InputOptions{})
+	if err != nil || got != "my-app" {
+		t.Fatalf("expected my-app, nil; got %q, %v", got, err)
+	}
+	if buf.String() != "» Project name   my-app  \n" {
+		t.Fatalf("unexpected transcript %q", buf.String())
+	}
+}
+
+func TestInputDefault(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("\n"))
+	got, err := o.Input("Region", InputOptions{Default: "eu-west-1"})
+	if err != nil || got != "eu-west-1" {
+		t.Fatalf("expected default, got %q, %v", got, err)
+	}
+	if !strings.Contains(buf.String(), "Region [eu-west-1]") {
+		t.Fatalf("expected default in the hint, got %q", buf.String())
+	}
+}
+
+func TestInputValidatorRepeats(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetReader(strings.NewReader("abc\n42\n"))
+	got, err := o.Input("Port", InputOptions{Validate: func(s string) error {
+		if _, err := strconv.Atoi(s); err != nil {
+			return errors.New("must be a number")
+		}
+		return nil
+	}})
+	if err != nil || got != "42" {
+		t.Fatalf("expected 42, nil; got %q, %v", got, err)
+	}
+	if !strings.Contains(buf.String(), ThemeDefault.ErrorColor.apply("must be a number", true)) {
+		t.Fatalf("expected validation error in ErrorColor, got %q", buf.String())
+	}
+	if strings.Count(buf.String(), "Port") != 2 {
+		t.Fatalf("expected the question twice, got %q", buf.String())
+	}
+}
+
+func TestInputEnvFallback(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	o, buf := newTestOutput()
+	o.SetReader(f)
+	t.Setenv("APP_NAME", " from-env ")
+	got, err := o.Input("Name", InputOptions{Env: "APP_NAME"})
+	if err != nil || got != "from-env" {
+		t.Fatalf("expected from-env, nil; got %q, %v", got, err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no prompt, got %q", buf.String())
+	}
+
+	_, err = o.Input("Name", InputOptions{Env: "APP_NAME", Validate: func(string) error { return errors.New("bad") }})
+	if err == nil || !strings.Contains(err.Error(), "APP_NAME: bad") {
+		t.Fatalf("expected a validation error naming the variable, got %v", err)
+	}
+
+	got, err = o.Input("Name", InputOptions{Env: "APP_UNSET", Default: "dflt"})
+	if !errors.Is(err, ErrNotInteractive) || got != "dflt" {
+		t.Fatalf("expected dflt, ErrNotInteractive; got %q, %v", got, err)
+	}
+}
+
+func TestPasswordDoesNotEchoLineInput(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("hunter2\n"))
+	got, err := o.Password("Password", InputOptions{Default: "ignored-in-hint"})
+	if err != nil || got != "hunter2" {
+		t.Fatalf("expected hunter2, nil; got %q, %v", got, err)
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "ignored-in-hint") {
+		t.Fatalf("expected the secret and default to stay hidden, got %q", buf.String())
+	}
+	if buf.String() != "» Password \n" {
+		t.Fatalf("unexpected transcript %q", buf.String())
+	}
+}
+
+func TestPasswordEnvFallback(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	o, _ := newTestOutput()
+	o.SetReader(f)
+	t.Setenv("APP_TOKEN", " s3cret ")
+	got, err := o.Password("Token", InputOptions{Env: "APP_TOKEN"})
+	if err != nil || got != " s3cret " {
+		t.Fatalf("expected untrimmed secret, got %q, %v", got, err)
+	}
+}
+
+func TestReadMaskedFrom(t *testing.T) {
+	o, buf := newTestOutput()
+	got, err := o.readMaskedFrom(strings.NewReader("abx\x7fc\033[Aé\r"))
+	if err != nil || got != "abcé" {
+		t.Fatalf("expected abcé, nil; got %q, %v", got, err)
+	}
+	if buf.String() != "***\b \b**\n" {
+		t.Fatalf("expected masked echo, got %q", buf.String())
+	}
+}
+
+func TestReadMaskedFromInterruptAndEOF(t *testing.T) {
+	o, buf := newTestOutput()
+	if _, err := o.readMaskedFrom(strings.NewReader("ab\x03")); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Fatalf("expected the line to be ended, got %q", buf.String())
+	}
+	if _, err := o.readMaskedFrom(strings.NewReader("\x04")); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if _, err := o.readMaskedFrom(strings.NewReader("ab")); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF when input ends without Enter, got %v", err)
+	}
+}
+
+// --- Form tests ---
+
+type formTestOptions struct {
+	Name     string   `prompt:"Project name" default:"my-app" required:"true"`
+	Region   string   `prompt:"Region" choices:"eu-west-1, us-east-1, ap-south-1" default:"us-east-1"`
+	Token    string   `prompt:"API token" secret:"true" required:"true"`
+	Workers  int      `prompt:"Workers" default:"4"`
+	Modules  []string `prompt:"Modules" choices:"api,web,cli"`
+	Public   bool     `prompt:"Public?" default:"true"`
+	Internal string   // no prompt tag: left alone
+}
+
+func TestFormPromptsEachField(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader(strings.Join([]string{
+		"",       // name: default
+		"",       // region: default
+		"",       // token: required, rejected
+		"s3cret", // token
+		"many",   // workers: rejected
+		"8",      // workers
+		"1,3",    // modules
+		"n",      // public
+	}, "\n") + "\n"))
+	opts := formTestOptions{Internal: "keep"}
+	if err := o.Form(&opts); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, buf.String())
+	}
+	want := formTestOptions{
+		Name: "my-app", Region: "us-east-1", Token: "s3cret", Workers: 8,
+		Modules: []string{"api", "cli"}, Public: false, Internal: "keep",
+	}
+	if fmt.Sprint(opts) != fmt.Sprint(want) {
+		t.Fatalf("expected %+v, got %+v", want, opts)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"Project name [my-app]",
+		"Choice [1-3, default 2]",
+		"a value is required",
+		`"many" is not a whole number`,
+		"Public? [Y/n]",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in transcript:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("expected the secret not to be echoed:\n%s", out)
+	}
+}
+
+func TestFormValidationErrorsUseErrorColor(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetReader(strings.NewReader("x\n5\n"))
+	var opts struct {
+		N int `prompt:"N"`
+	}
+	if err := o.Form(&opts); err != nil || opts.N != 5 {
+		t.Fatalf("expected 5, nil; got %d, %v", opts.N, err)
+	}
+	if !strings.Contains(buf.String(), ThemeDefault.ErrorColor.apply(`"x" is not a whole number`, true)) {
+		t.Fatalf("expected validation error in ErrorColor, got %q", buf.String())
+	}
+}
+
+func TestFormCurrentValueIsDefault(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("\n"))
+	opts := struct {
+		Host string `prompt:"Host"`
+	}{Host: "localhost"}
+	if err := o.Form(&opts); err != nil || opts.Host != "localhost" {
+		t.Fatalf("expected localhost, nil; got %q, %v", opts.Host, err)
+	}
+	if !strings.Contains(buf.String(), "Host [localhost]") {
+		t.Fatalf("expected current value as default, got %q", buf.String())
+	}
+}
+
+func TestFormEnvPrefillsDefault(t *testing.T) {
+	t.Setenv("FORM_REGION", "ap-south-1")
+	o, buf := newTestOutput()
+	o.SetReader(strings.NewReader("\n"))
+	var opts struct {
+		Region string `prompt:"Region" choices:"eu-west-1,ap-south-1" env:"FORM_REGION"`
+	}
+	if err := o.Form(&opts); err != nil || opts.Region != "ap-south-1" {
+		t.Fatalf("expected ap-south-1, nil; got %q, %v", opts.Region, err)
+	}
+	if !strings.Contains(buf.String(), "default 2") {
+		t.Fatalf("expected env value as default, got %q", buf.String())
+	}
+}
+
+func TestFormNonInteractive(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	type ciOptions struct {
+		Name    string   `prompt:"Name" required:"true" env:"FORM_NAME"`
+		Workers int      `prompt:"Workers" default:"4" env:"FORM_WORKERS"`
+		Modules []string `prompt:"Modules" choices:"api,web" env:"FORM_MODULES"`
+		Public  bool     `prompt:"Public?" env:"FORM_PUBLIC"`
+	}
+
+	o, buf := newTestOutput()
+	o.SetReader(f)
+	t.Setenv("FORM_NAME", "svc")
+	t.Setenv("FORM_MODULES", "web, api")
+	t.Setenv("FORM_PUBLIC", "true")
+	var opts ciOptions
+	if err := o.Form(&opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(opts) != fmt.Sprint(ciOptions{"svc", 4, []string{"web", "api"}, true}) {
+		t.Fatalf("unexpected values %+v", opts)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no prompts, got %q", buf.String())
+	}
+
+	t.Setenv("FORM_WORKERS", "lots")
+	err = o.Form(&ciOptions{})
+	if err == nil || err.Error() != `cliout: Workers (FORM_WORKERS): "lots" is not a whole number` {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	os.Unsetenv("FORM_NAME")
+	err = o.Form(&ciOptions{})
+	if !errors.Is(err, errRequired) || !strings.Contains(err.Error(), "Name (FORM_NAME)") {
+		t.Fatalf("expected a required error naming the variable, got %v", err)
+	}
+
+	t.Setenv("FORM_NAME", "svc")
+	t.Setenv("FORM_WORKERS", "2")
+	t.Setenv("FORM_MODULES", "api,db")
+	if err := o.Form(&ciOptions{}); err == nil || !strings.Contains(err.Error(), `"db" is not one of api, web`) {
+		t.Fatalf("expected an invalid choice error, got %v", err)
+	}
+}
+
+func TestFormRejectsBadArguments(t *testing.T) {
+	o, _ := newTestOutput()
+	var s struct{}
+	for _, v := range []any{nil, s, (*struct{})(nil), new(int)} {
+		if err := o.Form(v); err == nil {
+			t.Errorf("expected an error for %T", v)
+		}
+	}
+	var bad struct {
+		M map[string]string `prompt:"M"`
+	}
+	if err := o.Form(&bad); err == nil || !strings.Contains(err.Error(), "unsupported type") {
+		t.Fatalf("expected an unsupported type error, got %v", err)
+	}
+	var list struct {
+		L []string `prompt:"L"`
+	}
+	if err := o.Form(&list); err == nil {
+		t.Fatal("expected an error for a slice without choices")
+	}
+}
+
+func TestFormPropagatesEOF(t *testing.T) {
+	o, _ := newTestOutput()
+	o.SetReader(strings.NewReader(""))
+	var opts struct {
+		Name string `prompt:"Name"`
+	}
+	if err := o.Form(&opts); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+// --- Group tests ---
+
+func TestGroupIndentsLines(t *testing.T) {
+	o, buf := newTestOutput()
+	build := o.Group("building")
+	build.Info("compiling")
+	test := build.Group("testing")
+	test.Warn("1 skipped")
+	test.End()
+	build.Success("built")
+	build.End()
+	o.Info("done")
+
+	want := "» building\n" +
+		"»   compiling\n" +
+		"»   testing\n" +
+		"»     1 skipped\n" +
+		"»   built\n" +
+		"» done\n"
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestGroupEndedChildPrintsAtParentIndent(t *testing.T) {
+	o, buf := newTestOutput()
+	g := o.Group("phase")
+	g.End()
+	g.End() // idempotent
+	g.Info("after")
+	o.End() // not a group: no-op
+	if !strings.HasSuffix(buf.String(), "» after\n") {
+		t.Fatalf("expected unindented line after End, got %q", buf.String())
+	}
+}
+
+func TestGroupGuides(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetGroupStyle(GroupGuides)
+	g := o.Group("outer")
+	g.Group("inner").Info("deep")
+	want := "» outer\n» │ inner\n» │ │ deep\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+
+	o, buf = newTestOutput()
+	o.SetGroupStyle(GroupGuides)
+	o.SetColorEnabled(true)
+	o.Group("outer").Info("x")
+	if !strings.Contains(buf.String(), ThemeDefault.PrefixColor.apply("│ ", true)) {
+		t.Fatalf("expected guides in PrefixColor, got %q", buf.String())
+	}
+}
+
+func TestGroupWithoutPrefix(t *testing.T) {
+	o, buf := newTestOutput()
+	o.ClearPrefix()
+	o.Group("phase").Info("step")
+	if buf.String() != "phase\n  step\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestGroupLevelFiltering(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetLevel(LevelWarn)
+	g := o.Group("hidden heading")
+	g.Info("hidden")
+	g.Warn("shown")
+	if buf.String() != "»   shown\n" {
+		t.Fatalf("expected only the warning, got %q", buf.String())
+	}
+}
+
+func TestGroupKeepsFieldsAndWith(t *testing.T) {
+	o, buf := newTestOutput()
+	g := o.With(F("svc", "api")).Group("deploy")
+	g.With(F("step", 1)).Info("rolling out")
+	if !strings.HasSuffix(buf.String(), "»   rolling out svc=api step=1\n") {
+		t.Fatalf("expected indented line with fields, got %q", buf.String())
+	}
+}
+
+func TestGroupIndentsTables(t *testing.T) {
+	o, buf := newTestOutput()
+	tbl := o.Group("summary").Table("A", "B")
+	tbl.AddRow("1", "2")
+	tbl.Render()
+	want := "» summary\n»   A  B\n»   1  2\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestGroupIndentsSpinnerRow(t *testing.T) {
+	o, _ := newTestOutput()
+	s := o.Group("phase").Spinner("working")
+	o.mu.Lock()
+	row := stripANSI(s.render())
+	o.mu.Unlock()
+	if !strings.HasSuffix(row, "   working") {
+		t.Fatalf("expected indented spinner message, got %q", row)
+	}
+	s.Stop()
+}
+
+func TestGroupJSONFormat(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetFormat(FormatJSON)
+	o.Group("phase").Info("step")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"msg":"phase"`) || !strings.Contains(lines[1], `"msg":"step"`) {
+		t.Fatalf("expected heading and unindented message, got %q", buf.String())
+	}
+}
+
+func TestPackageLevelGroup(t *testing.T) {
+	buf, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	SetGroupStyle(GroupGuides)
+	Group("phase").Info("step")
+	if buf.String() != "» phase\n» │ step\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+// --- Color profile tests ---
+
+func TestDetectProfile(t *testing.T) {
+	tests := []struct {
+		colorterm, term, program, wtSession string
+		want                                ColorProfile
+	}{
+		{"truecolor", "xterm-256color", "", "", ProfileTrueColor},
+		{"24bit", "", "", "", ProfileTrueColor},
+		{"", "dumb", "", "", ProfileNone},
+		{"truecolor", "dumb", "", "", ProfileTrueColor},
+		{"", "xterm-direct", "", "", ProfileTrueColor},
+		{"", "xterm-256color", "", "", ProfileANSI256},
+		{"", "screen-256color", "", "", ProfileANSI256},
+		{"", "xterm-256color", "iTerm.app", "", ProfileTrueColor},
+		{"", "xterm-256color", "Apple_Terminal", "", ProfileANSI256},
+		{"", "xterm", "", "some-guid", ProfileTrueColor},
+		{"", "xterm", "", "", ProfileANSI},
+		{"", "linux", "", "", ProfileANSI},
+		{"", "", "", "", ProfileANSI},
+	}
+	for _, tt := range tests {
+		t.Setenv("COLORTERM", tt.colorterm)
+		t.Setenv("TERM", tt.term)
+		t.Setenv("TERM_PROGRAM", tt.program)
+		t.Setenv("WT_SESSION", tt.wtSession)
+		if got := detectProfile(); got != tt.want {
+			t.Errorf("COLORTERM=%q TERM=%q TERM_PROGRAM=%q WT_SESSION=%q: expected %v, got %v",
+				tt.colorterm, tt.term, tt.program, tt.wtSession, tt.want, got)
+		}
+	}
+}
+
+func TestNewDetectsProfile(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
+	t.Setenv("COLORTERM", "")
+	t.Setenv("TERM", "xterm-256color")
+	t.Setenv("TERM_PROGRAM", "")
+	t.Setenv("WT_SESSION", "")
+	if got := New().ColorProfile(); got != ProfileANSI256 {
+		t.Fatalf("expected ansi256, got %v", got)
+	}
+}
+
+func TestColorProfileString(t *testing.T) {
+	names := map[ColorProfile]string{
+		ProfileNone:      "none",
+		ProfileANSI:      "ansi",
+		ProfileANSI256:   "ansi256",
+		ProfileTrueColor: "truecolor",
+		ColorProfile(99): "unknown",
+	}
+	for p, want := range names {
+		if got := p.String(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestRGBTo256(t *testing.T) {
+	tests := []struct {
+		r, g, b uint8
+		want    uint8
+	}{
+		{0, 0, 0, 16},
+		{255, 255, 255, 231},
+		{255, 0, 0, 196},
+		{0, 255, 0, 46},
+		{0, 0, 255, 21},
+		{95, 135, 175, 67},
+		{255, 135, 0, 208},
+		{100, 200, 50, 77},
+		{128, 128, 128, 244},
+		{8, 8, 8, 232},
+		{238, 238, 238, 255},
+		{30, 30, 30, 234},
+		{250, 250, 250, 231},
+	}
+	for _, tt := range tests {
+		if got := rgbTo256(tt.r, tt.g, tt.b); got != tt.want {
+			t.Errorf("rgbTo256(%d, %d, %d): expected %d, got %d", tt.r, tt.g, tt.b, tt.want, got)
+		}
+	}
+}
+
+func TestRGBTo16(t *testing.T) {
+	tests := []struct {
+		r, g, b uint8
+		want    int
+	}{
+		{0, 0, 0, 30},
+		{200, 10, 10, 31},
+		{0, 205, 0, 32},
+		{205, 205, 0, 33},
+		{0, 0, 200, 34},
+		{205, 0, 205, 35},
+		{0, 205, 205, 36},
+		{229, 229, 229, 37},
+		{127, 127, 127, 90},
+		{255, 30, 30, 91},
+		{20, 255, 20, 92},
+		{255, 255, 60, 93},
+		{90, 90, 255, 94},
+		{255, 20, 255, 95},
+		{30, 255, 255, 96},
+		{255, 255, 255, 97},
+	}
+	for _, tt := range tests {
+		if got := rgbTo16(tt.r, tt.g, tt.b); got != tt.want {
+			t.Errorf("rgbTo16(%d, %d, %d): expected %d, got %d", tt.r, tt.g, tt.b, tt.want, got)
+		}
+	}
+}
+
+func TestColorRenderProfiles(t *testing.T) {
+	c := RGB(255, 135, 0)
+	tests := []struct {
+		p    ColorProfile
+		want string
+	}{
+		{ProfileTrueColor, "\033[38;2;255;135;0mhi\033[0m"},
+		{ProfileANSI256, "\033[38;5;208mhi\033[0m"},
+		{ProfileANSI, "\033[33mhi\033[0m"},
+		{ProfileNone, "hi"},
+	}
+	for _, tt := range tests {
+		if got := c.render("hi", tt.p); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.p, tt.want, got)
+		}
+	}
+	// ANSI colors are the same in every profile that has color.
+	if got := ColorCyan.render("hi", ProfileANSI); got != "\033[36mhi\033[0m" {
+		t.Fatalf("expected ANSI cyan unchanged, got %q", got)
+	}
+}
+
+func TestSetColorProfileDownsamplesOutput(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetColorProfile(ProfileANSI256)
+	o.SetPrefixColor(RGB(255, 0, 0))
+	o.SetMessageColor(RGB(0, 0, 255))
+	o.Info("hello")
+	want := "\033[38;5;196m»\033[0m \033[38;5;21mhello\033[0m\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+	if got := o.Colorize("x", RGB(0, 255, 0)); got != "\033[38;5;46mx\033[0m" {
+		t.Fatalf("expected Colorize to downsample, got %q", got)
+	}
+
+	buf.Reset()
+	o.SetColorProfile(ProfileNone)
+	o.Info("plain")
+	if buf.String() != "» plain\n" {
+		t.Fatalf("expected no color with ProfileNone, got %q", buf.String())
+	}
+}
+
+func TestPackageLevelSetColorProfile(t *testing.T) {
+	_, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	SetColorProfile(ProfileANSI)
+	if got := Default().ColorProfile(); got != ProfileANSI {
+		t.Fatalf("expected ansi, got %v", got)
+	}
+}
+
+// --- 256-color palette tests ---
+
+func TestColor256Render(t *testing.T) {
+	c := Color256(208)
+	if c.isDefault() {
+		t.Fatal("Color256 should not be default")
+	}
+	if Color256(0).isDefault() {
+		t.Fatal("Color256(0) should not be default (it's palette black)")
+	}
+	tests := []struct {
+		p    ColorProfile
+		want string
+	}{
+		{ProfileTrueColor, "\033[38;5;208mhi\033[0m"},
+		{ProfileANSI256, "\033[38;5;208mhi\033[0m"},
+		{ProfileANSI, "\033[33mhi\033[0m"},
+		{ProfileNone, "hi"},
+	}
+	for _, tt := range tests {
+		if got := c.render("hi", tt.p); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.p, tt.want, got)
+		}
+	}
+	if got := c.apply("hi", true); got != "\033[38;5;208mhi\033[0m" {
+		t.Fatalf("expected apply to use the palette, got %q", got)
+	}
+}
+
+func TestColor256SystemColorsOnANSI(t *testing.T) {
+	if got := Color256(1).render("x", ProfileANSI); got != "\033[31mx\033[0m" {
+		t.Fatalf("expected red, got %q", got)
+	}
+	if got := Color256(12).render("x", ProfileANSI); got != "\033[94mx\033[0m" {
+		t.Fatalf("expected bright blue, got %q", got)
+	}
+}
+
+func TestCube(t *testing.T) {
+	tests := []struct {
+		r, g, b uint8
+		want    uint8
+	}{
+		{0, 0, 0, 16},
+		{5, 5, 5, 231},
+		{5, 0, 0, 196},
+		{1, 2, 3, 67},
+		{9, 0, 0, 196},
+	}
+	for _, tt := range tests {
+		if got := Cube(tt.r, tt.g, tt.b); got != Color256(tt.want) {
+			t.Errorf("Cube(%d, %d, %d): expected %d, got %+v", tt.r, tt.g, tt.b, tt.want, got)
+		}
+	}
+}
+
+func TestGrayscale(t *testing.T) {
+	if got := Grayscale(0); got != Color256(232) {
+		t.Fatalf("expected 232, got %+v", got)
+	}
+	if got := Grayscale(23); got != Color256(255) {
+		t.Fatalf("expected 255, got %+v", got)
+	}
+	if got := Grayscale(40); got != Color256(255) {
+		t.Fatalf("expected levels past 23 to clamp, got %+v", got)
+	}
+}
+
+func TestPaletteRGB(t *testing.T) {
+	tests := []struct {
+		n       uint8
+		r, g, b uint8
+	}{
+		{0, 0, 0, 0},
+		{9, 255, 0, 0},
+		{16, 0, 0, 0},
+		{67, 95, 135, 175},
+		{208, 255, 135, 0},
+		{231, 255, 255, 255},
+		{232, 8, 8, 8},
+		{255, 238, 238, 238},
+	}
+	for _, tt := range tests {
+		r, g, b := paletteRGB(tt.n)
+		if r != tt.r || g != tt.g || b != tt.b {
+			t.Errorf("paletteRGB(%d): expected (%d, %d, %d), got (%d, %d, %d)", tt.n, tt.r, tt.g, tt.b, r, g, b)
+		}
+		// Every cube and grayscale entry maps back to itself.
+		if tt.n >= 16 && rgbTo256(r, g, b) != tt.n {
+			t.Errorf("rgbTo256(paletteRGB(%d)) = %d", tt.n, rgbTo256(r, g, b))
+		}
+	}
+}
+
+func TestThemeWith256Colors(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.SetTheme(Theme{Name: "256", PrefixColor: Cube(5, 2, 0), InfoColor: Grayscale(12)})
+	o.Info("hello")
+	want := "\033[38;5;208m»\033[0m \033[38;5;244mhello\033[0m\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+// --- Text attribute tests ---
+
+func TestColorAttributes(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Color
+		want string
+	}{
+		{"bold", ColorDefault.Bold(), "\033[1mhi\033[0m"},
+		{"dim", ColorDefault.Dim(), "\033[2mhi\033[0m"},
+		{"italic", ColorDefault.Italic(), "\033[3mhi\033[0m"},
+		{"underline", ColorDefault.Underline(), "\033[4mhi\033[0m"},
+		{"reverse", ColorDefault.Reverse(), "\033[7mhi\033[0m"},
+		{"strikethrough", ColorDefault.Strikethrough(), "\033[9mhi\033[0m"},
+		{"bold red", ColorRed.Bold(), "\033[1;31mhi\033[0m"},
+		{"combined", RGB(1, 2, 3).Underline().Bold(), "\033[1;4;38;2;1;2;3mhi\033[0m"},
+		{"background", ColorWhite.On(ColorRed), "\033[37;41mhi\033[0m"},
+		{"bright background", ColorBlack.On(ColorBrightGreen), "\033[30;102mhi\033[0m"},
+		{"true color background", ColorDefault.On(RGB(10, 20, 30)), "\033[48;2;10;20;30mhi\033[0m"},
+		{"palette background", Color256(15).On(Color256(208)).Bold(), "\033[1;38;5;15;48;5;208mhi\033[0m"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.apply("hi", true); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestColorAttributesNotDefault(t *testing.T) {
+	if ColorDefault.Bold().isDefault() {
+		t.Fatal("a bold style should not be default")
+	}
+	if ColorDefault.On(ColorRed).isDefault() {
+		t.Fatal("a background-only style should not be default")
+	}
+	if ColorRed.Bold() == ColorRed {
+		t.Fatal("Bold should return a different style")
+	}
+	if ColorRed.Bold().Bold() != ColorRed.Bold() {
+		t.Fatal("Bold should be idempotent")
+	}
+}
+
+func TestColorAttributesDisabled(t *testing.T) {
+	if got := ColorRed.Bold().On(ColorBlue).apply("hi", false); got != "hi" {
+		t.Fatalf("expected plain text, got %q", got)
+	}
+	if got := ColorRed.Bold().render("hi", ProfileNone); got != "hi" {
+		t.Fatalf("expected plain text with ProfileNone, got %q", got)
+	}
+}
+
+func TestBackgroundDownsampled(t *testing.T) {
+	c := ColorDefault.On(RGB(255, 0, 0)).Bold()
+	if got := c.render("x", ProfileANSI256); got != "\033[1;48;5;196mx\033[0m" {
+		t.Fatalf("expected 256-color background, got %q", got)
+	}
+	if got := c.render("x", ProfileANSI); got != "\033[1;101mx\033[0m" {
+		t.Fatalf("expected ANSI background, got %q", got)
+	}
+	if got := ColorDefault.On(Color256(208)).render("x", ProfileANSI); got != "\033[43mx\033[0m" {
+		t.Fatalf("expected palette background mapped to ANSI, got %q", got)
+	}
+}
+
+func TestStyledThemeAndMessageColor(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	theme := ThemeDefault
+	theme.ErrorColor = ColorRed.Bold()
+	theme.DebugColor = ColorDefault.Dim()
+	o.SetTheme(theme)
+	o.Error("failed")
+	o.Debug("details")
+	got := buf.String()
+	if !strings.Contains(got, "\033[1;31mfailed\033[0m") {
+		t.Fatalf("expected bold red error, got %q", got)
+	}
+	if !strings.Contains(got, "\033[2mdetails\033[0m") {
+		t.Fatalf("expected dim debug output, got %q", got)
+	}
+
+	buf.Reset()
+	o.SetMessageColor(ColorCyan.Italic())
+	o.Info("styled")
+	if !strings.Contains(buf.String(), "\033[3;36mstyled\033[0m") {
+		t.Fatalf("expected italic cyan message, got %q", buf.String())
+	}
+	if got := o.Colorize("x", ColorDefault.Underline()); got != "\033[4mx\033[0m" {
+		t.Fatalf("expected underlined text, got %q", got)
+	}
+}
+
+func TestStyledMultilineReopened(t *testing.T) {
+	o, buf := newTestOutput()
+	o.SetColorEnabled(true)
+	o.ClearPrefix()
+	o.Info("a " + o.Colorize("b\nc", ColorRed.Bold()) + " d")
+	want := "a \033[1;31mb\033[0m\n\033[1;31mc\033[0m d\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+// --- Badge tests ---
+
+func TestReadableOn(t *testing.T) {
+	tests := []struct {
+		name string
+		bg   Color
+		want Color
+	}{
+		{"white", RGB(255, 255, 255), RGB(0, 0, 0)},
+		{"black", RGB(0, 0, 0), RGB(255, 255, 255)},
+		{"yellow", Hex("#F1FA8C"), RGB(0, 0, 0)},
+		{"navy", Hex("#1E3A8A"), RGB(255, 255, 255)},
+		{"ansi green", ColorGreen, RGB(0, 0, 0)},
+		{"ansi blue", ColorBlue, RGB(255, 255, 255)},
+		{"ansi bright black", ColorBrightBlack, RGB(0, 0, 0)},
+		{"palette red", Color256(124), RGB(255, 255, 255)},
+		{"grayscale light", Grayscale(20), RGB(0, 0, 0)},
+	}
+	for _, tt := range tests {
+		if got := readableOn(tt.bg.tone); got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestContrastRatio(t *testing.T) {
+	if got := contrastRatio(1, 0); got != 21 {
+		t.Fatalf("expected 21 for white on black, got %v", got)
+	}
+	if got := contrastRatio(0.5, 0.5); got != 1 {
+		t.Fatalf("expected 1 for equal luminance, got %v", got)
+	}
+	if contrastRatio(0, 1) != contrastRatio(1, 0) {
+		t.Fatal("contrast ratio should not depend on argument order")
+	}
+}
+
+func TestBadge(t *testing.T) {
+	o, _ := newTestOutput()
+	o.SetColorEnabled(true)
+
+	if got := o.Badge("PASS", RGB(0, 200, 0)); got != "\033[1;38;2;0;0;0;48;2;0;200;0m PASS \033[0m" {
+		t.Fatalf("unexpected pass badge %q", got)
+	}
+	if got := o.Badge("FAIL", RGB(180, 0, 0)); got != "\033[1;38;2;255;255;255;48;2;180;0;0m FAIL \033[0m" {
+		t.Fatalf("unexpected fail badge %q", got)
+	}
+	if got := o.Badge("NOTE", ColorDefault); got != "\033[1;7m NOTE \033[0m" {
+		t.Fatalf("expected reversed default badge, got %q", got)
+	}
+	// Only bg's foreground is used.
+	if got := o.Badge("X", ColorRed.On(ColorBlue).Italic()); got != o.Badge("X", ColorRed) {
+		t.Fatalf("expected background and attributes of bg to be ignored, got %q", got)
+	}
+
+	o.SetColorProfile(ProfileANSI)
+	if got := o.Badge("PASS", RGB(0, 200, 0)); got != "\033[1;30;42m PASS \033[0m" {
+		t.Fatalf("expected downsampled badge, got %q", got)
+	}
+}
+
+func TestBadgeColorDisabled(t *testing.T) {
+	o, _ := newTestOutput()
+	if got := o.Badge("PASS", ColorGreen); got != "[PASS]" {
+		t.Fatalf("expected bracketed label, got %q", got)
+	}
+	if visibleWidth(o.Badge("PASS", ColorGreen)) != len(" PASS ") {
+		t.Fatal("expected the plain badge to take the same width as the pill")
+	}
+
+	o.SetColorEnabled(true)
+	o.SetColorProfile(ProfileNone)
+	if got := o.Badge("PASS", ColorGreen); got != "[PASS]" {
+		t.Fatalf("expected bracketed label with ProfileNone, got %q", got)
+	}
+}
+
+func TestPackageLevelBadge(t *testing.T) {
+	buf, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	Info(Badge("OK", ColorGreen) + " done")
+	if buf.String() != "» [OK] done\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+// --- Theme file tests ---
+
+func TestParseColorText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Color
+	}{
+		{"", ColorDefault},
+		{"default", ColorDefault},
+		{"red", ColorRed},
+		{"Bright-Blue", ColorBrightBlue},
+		{"#FF5733", RGB(0xFF, 0x57, 0x33)},
+		{"#ff5733", RGB(0xFF, 0x57, 0x33)},
+		{"256:202", Color256(202)},
+		{"bold red", ColorRed.Bold()},
+		{"dim italic default", ColorDefault.Dim().Italic()},
+		{"white on #FF0000", ColorWhite.On(RGB(255, 0, 0))},
+		{"  underline   256:7  on  blue ", Color256(7).Underline().On(ColorBlue)},
+		{"on red", ColorDefault.On(ColorRed)},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.in)
+		if err != nil {
+			t.Errorf("parseColor(%q): unexpected error %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseColor(%q): expected %+v, got %+v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestParseColorTextErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"#FF573", `invalid color "#FF573": hex colors need 3 or 6 digits after #, "#FF573" has 5`},
+		{"#GG5733", `invalid color "#GG5733": 'G' in "#GG5733" is not a hex digit`},
+		{"256:300", `invalid color "256:300": 256-color index "300" is not a number from 0 to 255`},
+		{"256:x", `invalid color "256:x": 256-color index "x" is not a number from 0 to 255`},
+		{"purpel", `invalid color "purpel": unknown color name "purpel"`},
+		{"red blue", `invalid color "red blue": more than one foreground color`},
+		{"red on", `invalid color "red on": "on" must be followed by a background color`},
+		{"red on nope", `invalid color "red on nope": unknown color name "nope"`},
+	}
+	for _, tt := range tests {
+		_, err := parseColor(tt.in)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("parseColor(%q): expected error %q, got %v", tt.in, tt.want, err)
+		}
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	theme, err := ParseTheme([]byte(`{
+		"name": "Ocean",
+		"prefix": "#5FAFD7",
+		"info": "default",
+		"debug": "bright-black",
+		"trace": "256:240",
+		"warn": "#FFD75F",
+		"error": "bold #FF5F5F",
+		"success": "green"
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Theme{
+		Name:         "Ocean",
+		PrefixColor:  Hex("#5FAFD7"),
+		InfoColor:    ColorDefault,
+		DebugColor:   ColorBrightBlack,
+		TraceColor:   Color256(240),
+		WarnColor:    Hex("#FFD75F"),
+		ErrorColor:   Hex("#FF5F5F").Bold(),
+		SuccessColor: ColorGreen,
+	}
+	if theme != want {
+		t.Fatalf("expected %+v, got %+v", want, theme)
+	}
+}
+
+func TestParseThemeMissingFieldsAreDefault(t *testing.T) {
+	theme, err := ParseTheme([]byte(`{"name": "Sparse", "error": "red"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if theme != (Theme{Name: "Sparse", ErrorColor: ColorRed}) {
+		t.Fatalf("unexpected theme %+v", theme)
+	}
+}
+
+func TestParseThemeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bad color", `{"warn": "#FFD7"}`, `cliout: theme: "warn": invalid color "#FFD7": hex colors need 3 or 6 digits after #, "#FFD7" has 4`},
+		{"unknown field", `{"colour": "red"}`, `cliout: theme: json: unknown field "colour"`},
+		{"wrong type", `{"info": 12}`, `cliout: theme: "info": expected a string, got a JSON number`},
+		{"syntax", "{\n  \"info\": \"red\",\n}", "cliout: theme: line 3, column 1: invalid character '}' looking for beginning of object key string"},
+		{"trailing data", `{"name":"x"} trailing`, "cliout: theme: line 1, column 14: unexpected data after the theme object"},
+		{"second object", "{\"name\":\"x\"}\n\n  {}", "cliout: theme: line 3, column 3: unexpected data after the theme object"},
+	}
+	for _, tt := range tests {
+		_, err := ParseTheme([]byte(tt.in))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/ocean.json"
+	if err := os.WriteFile(path, []byte(`{"prefix": "cyan", "error": "bold red"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	theme, err := LoadTheme(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if theme.Name != "ocean" {
+		t.Fatalf("expected the theme to be named after the file, got %q", theme.Name)
+	}
+	if theme.PrefixColor != ColorCyan || theme.ErrorColor != ColorRed.Bold() {
+		t.Fatalf("unexpected theme %+v", theme)
+	}
+}
+
+func TestLoadThemeErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/broken.json"
+	if err := os.WriteFile(path, []byte(`{"error": "rouge"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadTheme(path)
+	want := "cliout: " + path + `: "error": invalid color "rouge": unknown color name "rouge"`
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+
+	_, err = LoadTheme(dir + "/missing.json")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a not-exist error, got %v", err)
+	}
+}
+
+func TestNewCliThemeEnvPath(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
+	path := t.TempDir() + "/mine.json"
+	if err := os.WriteFile(path, []byte(`{"name": "Mine", "prefix": "#123456"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLI_THEME", path)
+	o := New()
+	if o.theme.Name != "Mine" || o.theme.PrefixColor != Hex("#123456") {
+		t.Fatalf("expected theme loaded from file, got %+v", o.theme)
+	}
+}
+
+// captureThemeErr redirects os.Stderr to a file for the rest of the test and
+// lets New report a CLI_THEME error again. It returns a function that reads
+// what has been written.
+func captureThemeErr(t *testing.T) func() string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stderr
+	os.Stderr = f
+	themeErrOnce = sync.Once{}
+	t.Cleanup(func() {
+		os.Stderr = orig
+		f.Close()
+	})
+	return func() string {
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
+
+func TestNewCliThemeEnvInvalidFileFallsBackToDefault(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
+	path := t.TempDir() + "/bad.json"
+	if err := os.WriteFile(path, []byte(`{"prefix": "#12"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLI_THEME", path)
+	stderr := captureThemeErr(t)
+	o := New()
+	if o.theme.Name != ThemeDefault.Name {
+		t.Fatalf("expected default theme, got %q", o.theme.Name)
+	}
+	if o.pendingTheme != "" {
+		t.Fatalf("expected no pending theme for a file path, got %q", o.pendingTheme)
+	}
+	want := "cliout: CLI_THEME: " + path + `: "prefix": invalid color "#12": hex colors need 3 or 6 digits after #, "#12" has 2` + "\n"
+	if got := stderr(); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	New()
+	if got := stderr(); got != want {
+		t.Fatalf("expected the error to be reported once, got %q", got)
+	}
+}
+
+func TestNewCliThemeEnvMissingFileWarns(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
+	path := filepath.Join(t.TempDir(), "missing.json")
+	t.Setenv("CLI_THEME", path)
+	stderr := captureThemeErr(t)
+	if o := New(); o.theme.Name != ThemeDefault.Name {
+		t.Fatalf("expected default theme, got %q", o.theme.Name)
+	}
+	got := stderr()
+	if !strings.HasPrefix(got, "cliout: CLI_THEME: open "+path) {
+		t.Fatalf("expected missing file to be reported, got %q", got)
+	}
+}
+
+func TestNewCliThemeEnvUnknownNameIsSilent(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
+	t.Setenv("CLI_THEME", "not-registered-yet")
+	stderr := captureThemeErr(t)
+	o := New()
+	if o.pendingTheme != "not-registered-yet" {
+		t.Fatalf("expected the name to wait for registration, got %q", o.pendingTheme)
+	}
+	if got := stderr(); got != "" {
+		t.Fatalf("expected no warning for a theme name, got %q", got)
+	}
+}
+
+// --- Theme registry tests ---
+
+// registerTestTheme registers a theme for the duration of the test.
+func registerTestTheme(t *testing.T, theme Theme) {
+	t.Helper()
+	if err := RegisterTheme(theme); err != nil {
+		t.Fatalf("RegisterTheme(%q): %v", theme.Name, err)
+	}
+	t.Cleanup(func() { UnregisterTheme(theme.Name) })
+}
+
+func TestRegisterTheme(t *testing.T) {
+	acme := Theme{Name: "Acme", PrefixColor: Hex("#FF6600")}
+	registerTestTheme(t, acme)
+
+	themes := Themes()
+	if got := themes[len(themes)-1]; got != acme {
+		t.Fatalf("expected registered theme last in Themes, got %+v", got)
+	}
+	if len(themes) != len(builtinThemes())+1 {
+		t.Fatalf("expected one theme added, got %d themes", len(themes))
+	}
+	if got, ok := ThemeByName("ACME"); !ok || got != acme {
+		t.Fatalf("expected ThemeByName to find the registered theme, got %+v, %v", got, ok)
+	}
+}
+
+func TestRegisterThemeCollisions(t *testing.T) {
+	registerTestTheme(t, Theme{Name: "Acme"})
+
+	for _, name := range []string{"Dracula", "dracula", "acme"} {
+		err := RegisterTheme(Theme{Name: name})
+		if !errors.Is(err, ErrThemeExists) {
+			t.Errorf("RegisterTheme(%q): expected ErrThemeExists, got %v", name, err)
+		}
+	}
+	if err := RegisterTheme(Theme{}); err == nil {
+		t.Error("expected an error for a theme without a name")
+	}
+	if got, _ := ThemeByName("Dracula"); got != ThemeDracula {
+		t.Fatal("a built-in theme must not be replaced")
+	}
+}
+
+func TestUnregisterTheme(t *testing.T) {
+	if err := RegisterTheme(Theme{Name: "Temp"}); err != nil {
+		t.Fatal(err)
+	}
+	if !UnregisterTheme("temp") {
+		t.Fatal("expected the theme to be removed")
+	}
+	if _, ok := ThemeByName("Temp"); ok {
+		t.Fatal("expected the theme to be gone")
+	}
+	if UnregisterTheme("Temp") {
+		t.Fatal("expected a second removal to report false")
+	}
+	if UnregisterTheme("Dracula") {
+		t.Fatal("built-in themes must not be removable")
+	}
+	if _, ok := ThemeByName("Dracula"); !ok {
+		t.Fatal("expected Dracula to still exist")
+	}
+}
+
+func TestRegisterThemeAlias(t *testing.T) {
+	if err := RegisterThemeAlias("mocha", "catppuccino mocha"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { UnregisterTheme("mocha") })
+
+	if got, ok := ThemeByName("Mocha"); !ok || got != ThemeCatppuccinoMocha {
+		t.Fatalf("expected alias to resolve, got %+v, %v", got, ok)
+	}
+	for _, th := range Themes() {
+		if th.Name == "mocha" {
+			t.Fatal("aliases must not be listed by Themes")
+		}
+	}
+
+	if err := RegisterThemeAlias("mocha", "Dracula"); !errors.Is(err, ErrThemeExists) {
+		t.Fatalf("expected ErrThemeExists for a duplicate alias, got %v", err)
+	}
+	if err := RegisterThemeAlias("Nord", "Dracula"); !errors.Is(err, ErrThemeExists) {
+		t.Fatalf("expected ErrThemeExists for an alias shadowing a theme, got %v", err)
+	}
+	if err := RegisterTheme(Theme{Name: "Mocha"}); !errors.Is(err, ErrThemeExists) {
+		t.Fatalf("expected ErrThemeExists for a theme shadowing an alias, got %v", err)
+	}
+	if err := RegisterThemeAlias("x", "no such theme"); err == nil || errors.Is(err, ErrThemeExists) {
+		t.Fatalf("expected an unknown theme error, got %v", err)
+	}
+}
+
+func TestUnregisterThemeRemovesAliases(t *testing.T) {
+	if err := RegisterTheme(Theme{Name: "Acme Corporate"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterThemeAlias("acme", "Acme Corporate"); err != nil {
+		t.Fatal(err)
+	}
+	UnregisterTheme("Acme Corporate")
+	if _, ok := ThemeByName("acme"); ok {
+		t.Fatal("expected the alias to go with its theme")
+	}
+	if UnregisterTheme("acme") {
+		t.Fatal("expected the alias to be removed already")
+	}
+}
+
+func TestNewCliThemeEnvRegistered(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
+	acme := Theme{Name: "Acme", PrefixColor: Hex("#FF6600")}
+	registerTestTheme(t, acme)
+	if err := RegisterThemeAlias("company", "Acme"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { UnregisterTheme("company") })
+
+	t.Setenv("CLI_THEME", "acme")
+	if got := New().theme; got != acme {
+		t.Fatalf("expected registered theme from CLI_THEME, got %+v", got)
+	}
+	t.Setenv("CLI_THEME", "Company")
+	if got := New().theme; got != acme {
+		t.Fatalf("expected aliased theme from CLI_THEME, got %+v", got)
+	}
+}
+
+func TestDefaultOutputAdoptsThemeRegisteredLater(t *testing.T) {
+	_, cleanup := setupDefaultForTest()
+	defer cleanup()
+
+	d := Default()
+	d.pendingTheme = "Late"
+	late := Theme{Name: "Late", PrefixColor: ColorMagenta}
+	registerTestTheme(t, late)
+	if d.theme != late {
+		t.Fatalf("expected the default output to adopt the registered theme, got %+v", d.theme)
+	}
+
+	d.pendingTheme = "Later"
+	SetTheme(ThemeNord)
+	registerTestTheme(t, Theme{Name: "Later"})
+	if d.theme != ThemeNord {
+		t.Fatalf("expected SetTheme to take priority, got %+v", d.theme)
+	}
+}
+
+func TestNewRecordsUnknownCliTheme(t *testing.T) {
+	t.Setenv("CLI_FORMAT", "")
+	t.Setenv("CLI_THEME", "not-yet-registered")
+	o := New()
+	if o.pendingTheme != "not-yet-registered" || o.theme != ThemeDefault {
+		t.Fatalf("expected default theme with a pending name, got %q, %+v", o.pendingTheme, o.theme)
+	}
+}
+
+func TestThemeRegistryConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("Concurrent %d", i)
+			for j := 0; j < 50; j++ {
+				if err := RegisterTheme(Theme{Name: name}); err != nil {
+					t.Errorf("RegisterTheme: %v", err)
+					return
+				}
+				ThemeByName(name)
+				Themes()
+				UnregisterTheme(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(Themes()) != len(builtinThemes()) {
+		t.Fatalf("expected only built-in themes to remain, got %d", len(Themes()))
+	}
+}
+
+// --- Text marshalling tests ---
+
+func TestColorString(t *testing.T) {
+	tests := []struct {
+		c    Color
+		want string
+	}{
+		{ColorDefault, "default"},
+		{ColorRed, "red"},
+		{ColorBrightBlue, "bright-blue"},
+		{Hex("#ff5733"), "#FF5733"},
+		{Color256(202), "256:202"},
+		{ColorRed.Bold(), "bold red"},
+		{ColorDefault.Underline().Dim(), "dim underline"},
+		{ColorDefault.On(ColorBlue), "on blue"},
+		{ColorWhite.On(Hex("#C0392B")).Bold(), "bold white on #C0392B"},
+		{ColorDefault.Strikethrough().Reverse().Italic(), "italic reverse strikethrough"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+		if got := fmt.Sprint(tt.c); got != tt.want {
+			t.Errorf("expected fmt to use String, got %q", got)
+		}
+	}
+}
+
+func TestColorTextRoundTrip(t *testing.T) {
+	colors := []Color{
+		ColorDefault, ColorBlack, ColorBrightWhite, RGB(0, 0, 0), Hex("#123ABC"),
+		Color256(0), Cube(5, 2, 0), Grayscale(7),
+		ColorRed.Bold().On(Color256(17)), ColorDefault.On(RGB(1, 2, 3)),
+		ColorDefault.Bold().Dim().Italic().Underline().Strikethrough().Reverse(),
+	}
+	for _, c := range colors {
+		text, err := c.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%v): %v", c, err)
+		}
+		var got Color
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatalf("UnmarshalText(%q): %v", text, err)
+		}
+		if got != c {
+			t.Errorf("round trip of %q: expected %+v, got %+v", text, c, got)
+		}
+	}
+}
+
+func TestColorUnmarshalText(t *testing.T) {
+	var c Color
+	for in, want := range map[string]Color{
+		"#FF5733":     RGB(0xFF, 0x57, 0x33),
+		"red":         ColorRed,
+		"bright-blue": ColorBrightBlue,
+		"256:202":     Color256(202),
+	} {
+		if err := c.UnmarshalText([]byte(in)); err != nil || c != want {
+			t.Errorf("UnmarshalText(%q): expected %+v, got %+v, %v", in, want, c, err)
+		}
+	}
+
+	c = ColorRed
+	err := c.UnmarshalText([]byte("#12"))
+	if err == nil || err.Error() != `cliout: invalid color "#12": hex colors need 3 or 6 digits after #, "#12" has 2` {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if c != ColorRed {
+		t.Fatal("a failed UnmarshalText must leave the color unchanged")
+	}
+}
+
+func TestColorInJSON(t *testing.T) {
+	type config struct {
+		Accent Color `json:"accent"`
+	}
+	data, err := json.Marshal(config{Accent: ColorCyan.Bold()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"accent":"bold cyan"}` {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+	var got config
+	if err := json.Unmarshal(data, &got); err != nil || got.Accent != ColorCyan.Bold() {
+		t.Fatalf("expected bold cyan, got %+v, %v", got.Accent, err)
+	}
+}
+
+func TestThemeJSONRoundTrip(t *testing.T) {
+	for _, theme := range Themes() {
+		data, err := json.Marshal(theme)
+		if err != nil {
+			t.Fatalf("%s: %v", theme.Name, err)
+		}
+		var got Theme
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v", theme.Name, err)
+		}
+		if got != theme {
+			t.Errorf("%s: round trip through %s gave %+v", theme.Name, data, got)
+		}
+	}
+
+	data, _ := json.Marshal(ThemeDefault)
+	want := `{"name":"Default","prefix":"cyan","info":"default","debug":"bright-black","trace":"bright-black","warn":"yellow","error":"red","success":"green"}`
+	if string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+	if parsed, err := ParseTheme(data); err != nil || parsed != ThemeDefault {
+		t.Fatalf("expected marshalled JSON to be a valid theme file, got %+v, %v", parsed, err)
+	}
+}
+
+func TestThemeUnmarshalJSONErrors(t *testing.T) {
+	var cfg struct {
+		Theme Theme `json:"theme"`
+	}
+	err := json.Unmarshal([]byte(`{"theme": {"name": "x", "warn": "orange-ish"}}`), &cfg)
+	want := `cliout: theme: "warn": invalid color "orange-ish": unknown color name "orange-ish"`
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, got %v", want, err)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for l := LevelTrace; l <= LevelSilent; l++ {
+		got, err := ParseLevel(l.String())
+		if err != nil || got != l {
+			t.Errorf("ParseLevel(%q): expected %v, got %v, %v", l.String(), l, got, err)
+		}
+	}
+	if got, err := ParseLevel("  WARN "); err != nil || got != LevelWarn {
+		t.Fatalf("expected case and space to be ignored, got %v, %v", got, err)
+	}
+	_, err := ParseLevel("verbose")
+	if err == nil || err.Error() != `cliout: unknown level "verbose" (want trace, debug, info, warn, error or silent)` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestLevelTextMarshalling(t *testing.T) {
+	type config struct {
+		Level Level `json:"level"`
+	}
+	data, err := json.Marshal(config{Level: LevelDebug})
+	if err != nil || string(data) != `{"level":"debug"}` {
+		t.Fatalf("unexpected JSON %s, %v", data, err)
+	}
+	var got config
+	if err := json.Unmarshal([]byte(`{"level":"error"}`), &got); err != nil || got.Level != LevelError {
+		t.Fatalf("expected error level, got %v, %v", got.Level, err)
+	}
+	if err := json.Unmarshal([]byte(`{"level":"loud"}`), &got); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+	if _, err := Level(42).MarshalText(); err == nil {
+		t.Fatal("expected an error for an invalid level")
+	}
+}
+
+func TestLevelFlag(t *testing.T) {
+	var _ flag.Value = (*Level)(nil)
+
+	level := LevelInfo
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&level, "level", "output level")
+	if err := fs.Parse([]string{"-level", "trace"}); err != nil {
+		t.Fatal(err)
+	}
+	if level != LevelTrace {
+		t.Fatalf("expected trace, got %v", level)
+	}
+	if err := fs.Parse([]string{"-level", "noisy"}); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+	if level != LevelTrace {
+		t.Fatal("a failed Set must leave the level unchanged")
+	}
+}
+
+// --- ParseColor tests ---
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Color
+	}{
+		{"#F80", RGB(0xFF, 0x88, 0x00)},
+		{"#ff8800", RGB(0xFF, 0x88, 0x00)},
+		{"rgb(255, 136, 0)", RGB(255, 136, 0)},
+		{"RGB(255 136 0)", RGB(255, 136, 0)},
+		{"rgb(100%, 50%, 0%)", RGB(255, 128, 0)},
+		{"hsl(0, 100%, 50%)", RGB(255, 0, 0)},
+		{"hsl(120deg, 100%, 25%)", RGB(0, 128, 0)},
+		{"hsl(240, 100%, 50%)", RGB(0, 0, 255)},
+		{"hsl(-120, 100%, 50%)", RGB(0, 0, 255)},
+		{"hsl(30, 100%, 50%)", RGB(255, 128, 0)},
+		{"hsl(0, 0%, 50%)", RGB(128, 128, 128)},
+		{"coral", RGB(0xFF, 0x7F, 0x50)},
+		{"RebeccaPurple", RGB(0x66, 0x33, 0x99)},
+		{"grey", RGB(0x80, 0x80, 0x80)},
+		{"red", ColorRed},
+		{"bright-blue", ColorBrightBlue},
+		{"brightblue", ColorBrightBlue},
+		{"BrightBlue", ColorBrightBlue},
+		{"bright_blue", ColorBrightBlue},
+		{"256:202", Color256(202)},
+		{"default", ColorDefault},
+		{"", ColorDefault},
+		{"bold rgb(1, 2, 3) on hsl(0, 100%, 50%)", RGB(1, 2, 3).Bold().On(RGB(255, 0, 0))},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if err != nil {
+			t.Errorf("ParseColor(%q): unexpected error %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseColor(%q): expected %v, got %v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestParseColorErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"#12", `hex colors need 3 or 6 digits after #, "#12" has 2`},
+		{"#12345", `hex colors need 3 or 6 digits after #, "#12345" has 5`},
+		{"#XYZ", `'X' in "#XYZ" is not a hex digit`},
+		{"rgb(1, 2)", `rgb() needs 3 arguments, "rgb(1, 2)" has 2`},
+		{"rgb(1, 2, 300)", `rgb() component "300" is not a number from 0 to 255`},
+		{"rgb(1, 2, 101%)", `rgb() component "101%" is not a percentage from 0% to 100%`},
+		{"hsl(red, 50%, 50%)", `hsl() hue "red" is not a number of degrees`},
+		{"hsl(0, 150%, 50%)", `hsl() saturation "150%" is not a percentage from 0% to 100%`},
+		{"hsl(0, 50%, x)", `hsl() lightness "x" is not a percentage from 0% to 100%`},
+		{"rgb(1, 2, 3", `missing ")"`},
+		{"red)", `unexpected ")"`},
+		{"cornflour", `unknown color name "cornflour"`},
+	}
+	for _, tt := range tests {
+		c, err := ParseColor(tt.in)
+		want := fmt.Sprintf("cliout: invalid color %q: %s", tt.in, tt.want)
+		if err == nil || err.Error() != want {
+			t.Errorf("ParseColor(%q): expected error %q, got %v", tt.in, want, err)
+		}
+		if c != ColorDefault {
+			t.Errorf("ParseColor(%q): expected ColorDefault on error, got %v", tt.in, c)
+		}
+	}
+}
+
+func TestCSSColorsComplete(t *testing.T) {
+	// 148 CSS names, less the 8 that select ANSI colors.
+	if len(cssColors) != 140 {
+		t.Fatalf("expected 140 CSS colors, got %d", len(cssColors))
+	}
+	for name := range ansiNames {
+		if _, ok := cssColors[name]; ok {
+			t.Errorf("%q should select the ANSI color", name)
+		}
+	}
+}
+
+func TestHexShortForm(t *testing.T) {
+	if got := Hex("#F80"); got != RGB(0xFF, 0x88, 0x00) {
+		t.Fatalf("expected #F80 to expand, got %v", got)
+	}
+	if got := Hex("f80"); got != RGB(0xFF, 0x88, 0x00) {
+		t.Fatalf("expected f80 without # to expand, got %v", got)
+	}
+	if got := Hex("coral"); got != ColorDefault {
+		t.Fatalf("expected Hex to accept only hex, got %v", got)
+	}
+}
+
+func TestParseColorRoundTripsString(t *testing.T) {
+	for _, in := range []string{"rgb(10, 20, 30)", "hsl(200, 50%, 40%)", "peru", "#ABC"} {
+		c, err := ParseColor(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := ParseColor(c.String())
+		if err != nil || back != c {
+			t.Errorf("%q: String gave %q, which parsed to %v, %v", in, c.String(), back, err)
+		}
+	}
+}
+
+// --- Color manipulation tests ---
+
+func TestRGBHSLConversion(t *testing.T) {
+	tests := []struct {
+		r, g, b uint8
+		h, s, l float64
+	}{
+		{0, 0, 0, 0, 0, 0},
+		{255, 255, 255, 0, 0, 1},
+		{255, 0, 0, 0, 1, 0.5},
+		{0, 255, 0, 120, 1, 0.5},
+		{0, 0, 255, 240, 1, 0.5},
+		{255, 0, 255, 300, 1, 0.5},
+	}
+	for _, tt := range tests {
+		h, s, l := rgbToHSL(tt.r, tt.g, tt.b)
+		if math.Abs(h-tt.h) > 1e-9 || math.Abs(s-tt.s) > 1e-9 || math.Abs(l-tt.l) > 1e-9 {
+			t.Errorf("rgbToHSL(%d, %d, %d): expected (%v, %v, %v), got (%v, %v, %v)", tt.r, tt.g, tt.b, tt.h, tt.s, tt.l, h, s, l)
+		}
+	}
+	// Every color survives a round trip through HSL.
+	for _, c := range []Color{Hex("#BD93F9"), Hex("#1E3A8A"), Hex("#808080"), Hex("#F1FA8C"), Hex("#0A0B0C")} {
+		r, g, b := hslToRGB(rgbToHSL(c.r, c.g, c.b))
+		if RGB(r, g, b) != c {
+			t.Errorf("%v: round trip gave %v", c, RGB(r, g, b))
+		}
+	}
+}
+
+func TestOKLabRoundTrip(t *testing.T) {
+	for _, c := range []Color{Hex("#000000"), Hex("#FFFFFF"), Hex("#BD93F9"), Hex("#FF5733"), Hex("#00FF00")} {
+		r, g, b := fromOKLab(toOKLab(c.r, c.g, c.b))
+		if RGB(r, g, b) != c {
+			t.Errorf("%v: round trip gave %v", c, RGB(r, g, b))
+		}
+	}
+	if l, a, b := toOKLab(255, 255, 255); math.Abs(l-1) > 1e-4 || math.Abs(a) > 1e-4 || math.Abs(b) > 1e-4 {
+		t.Fatalf("expected white to be (1, 0, 0), got (%v, %v, %v)", l, a, b)
+	}
+}
+
+func TestLightenDarken(t *testing.T) {
+	red := RGB(255, 0, 0)
+	if got := red.Lighten(0.25); got != RGB(255, 128, 128) {
+		t.Fatalf("expected #FF8080, got %v", got)
+	}
+	if got := red.Darken(0.25); got != RGB(128, 0, 0) {
+		t.Fatalf("expected #800000, got %v", got)
+	}
+	if got := red.Lighten(2); got != RGB(255, 255, 255) {
+		t.Fatalf("expected lightening past white to clamp, got %v", got)
+	}
+	if got := red.Darken(2); got != RGB(0, 0, 0) {
+		t.Fatalf("expected darkening past black to clamp, got %v", got)
+	}
+	if got := red.Lighten(0); got != red {
+		t.Fatalf("expected no change, got %v", got)
+	}
+}
+
+func TestSaturateDesaturate(t *testing.T) {
+	muted := Hex("#996666") // hsl(0, 20%, 50%)
+	if got := muted.Saturate(0.3); got != Hex("#BF4040") {
+		t.Fatalf("expected #BF4040, got %v", got)
+	}
+	if got := muted.Desaturate(1); got != Hex("#808080") {
+		t.Fatalf("expected gray, got %v", got)
+	}
+	if got := Hex("#808080").Saturate(0.5); got != Hex("#C04141") {
+		t.Fatalf("expected a gray to saturate towards red, got %v", got)
+	}
+}
+
+func TestComplement(t *testing.T) {
+	tests := []struct{ in, want Color }{
+		{RGB(255, 0, 0), RGB(0, 255, 255)},
+		{Hex("#3366CC"), Hex("#CC9933")},
+		{Hex("#808080"), Hex("#808080")},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Complement(); got != tt.want {
+			t.Errorf("%v: expected %v, got %v", tt.in, tt.want, got)
+		}
+	}
+	if c := Hex("#3366CC"); c.Complement().Complement() != c {
+		t.Fatal("the complement of the complement should be the color")
+	}
+}
+
+func TestMix(t *testing.T) {
+	black, white := RGB(0, 0, 0), RGB(255, 255, 255)
+	if got := black.Mix(white, 0); got != black {
+		t.Fatalf("expected weight 0 to give the color, got %v", got)
+	}
+	if got := black.Mix(white, 1); got != white {
+		t.Fatalf("expected weight 1 to give the other color, got %v", got)
+	}
+	if got := black.Mix(white, 5); got != white {
+		t.Fatalf("expected weights past 1 to clamp, got %v", got)
+	}
+	// Mixing in OKLab gives its perceptual mid-gray, not the RGB average
+	// of #808080.
+	if got := black.Mix(white, 0.5); got != Hex("#636363") {
+		t.Fatalf("expected #636363, got %v", got)
+	}
+	// Blue and yellow do not pass through gray.
+	if got := RGB(0, 0, 255).Mix(RGB(255, 255, 0), 0.5); got.r == got.g && got.g == got.b {
+		t.Fatalf("expected a chromatic blend, got %v", got)
+	}
+}
+
+func TestColorManipulationANSIAndStyle(t *testing.T) {
+	// ANSI and palette colors start from their xterm RGB values.
+	if got := ColorRed.Lighten(0); got != RGB(205, 0, 0) {
+		t.Fatalf("expected ANSI red as #CD0000, got %v", got)
+	}
+	if got := Color256(196).Darken(0.25); got != RGB(128, 0, 0) {
+		t.Fatalf("expected palette red darkened, got %v", got)
+	}
+	if got := ColorBlue.Mix(ColorBlue, 0.5); got != RGB(0, 0, 238) {
+		t.Fatalf("expected ANSI blue as #0000EE, got %v", got)
+	}
+
+	// Background and attributes are kept; the default color is unchanged.
+	styled := Hex("#FF0000").Bold().On(ColorBlack)
+	if got := styled.Darken(0.25); got != RGB(128, 0, 0).Bold().On(ColorBlack) {
+		t.Fatalf("expected style to be kept, got %v", got)
+	}
+	bold := ColorDefault.Bold()
+	for name, got := range map[string]Color{
+		"lighten":    bold.Lighten(0.2),
+		"saturate":   bold.Saturate(0.2),
+		"complement": bold.Complement(),
+		"mix":        bold.Mix(ColorRed, 0.5),
+	} {
+		if got != bold {
+			t.Errorf("%s: expected the default color unchanged, got %v", name, got)
+		}
+	}
+	if got := ColorRed.Mix(ColorDefault, 0.5); got != ColorRed {
+		t.Fatalf("expected mixing with the default color to do nothing, got %v", got)
+	}
+}
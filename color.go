@@ -1,73 +1,276 @@
 package cliout
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
-// Color represents a color that can be applied to terminal output.
-// It supports standard ANSI colors and true color (24-bit RGB).
+// Color represents a style that can be applied to terminal output: a
+// foreground color, an optional background color and text attributes such
+// as bold. Colors may be standard ANSI colors, entries of the xterm
+// 256-color palette or true colors (24-bit RGB). The constructors create a
+// foreground color; use On, Bold and the other attribute methods to extend
+// it.
 type Color struct {
+	tone       // foreground
+	bg    tone // background; default when unset
+	attrs attr
+}
+
+// tone is a single foreground or background color.
+type tone struct {
 	r, g, b     uint8
 	isTrueColor bool
-	ansiCode    int
+	index       uint8 // 256-color palette entry; used when isIndexed is set
+	isIndexed   bool
+	ansiCode    int // foreground SGR code, 30-37 or 90-97
+}
+
+// attr is a set of SGR text attributes.
+type attr uint8
+
+const (
+	attrBold attr = 1 << iota
+	attrDim
+	attrItalic
+	attrUnderline
+	attrReverse
+	attrStrikethrough
+)
+
+// attrCodes lists each attribute with its SGR code, in the order they are
+// emitted.
+var attrCodes = []struct {
+	a    attr
+	code int
+}{
+	{attrBold, 1},
+	{attrDim, 2},
+	{attrItalic, 3},
+	{attrUnderline, 4},
+	{attrReverse, 7},
+	{attrStrikethrough, 9},
+}
+
+// ansiColor returns the standard ANSI color with the given foreground code.
+func ansiColor(code int) Color {
+	return Color{tone: tone{ansiCode: code}}
 }
 
 // Standard ANSI color constants.
 var (
 	ColorDefault = Color{}
-	ColorBlack   = Color{ansiCode: 30}
-	ColorRed     = Color{ansiCode: 31}
-	ColorGreen   = Color{ansiCode: 32}
-	ColorYellow  = Color{ansiCode: 33}
-	ColorBlue    = Color{ansiCode: 34}
-	ColorMagenta = Color{ansiCode: 35}
-	ColorCyan    = Color{ansiCode: 36}
-	ColorWhite   = Color{ansiCode: 37}
+	ColorBlack   = ansiColor(30)
+	ColorRed     = ansiColor(31)
+	ColorGreen   = ansiColor(32)
+	ColorYellow  = ansiColor(33)
+	ColorBlue    = ansiColor(34)
+	ColorMagenta = ansiColor(35)
+	ColorCyan    = ansiColor(36)
+	ColorWhite   = ansiColor(37)
 
 	// Bright variants.
-	ColorBrightBlack   = Color{ansiCode: 90}
-	ColorBrightRed     = Color{ansiCode: 91}
-	ColorBrightGreen   = Color{ansiCode: 92}
-	ColorBrightYellow  = Color{ansiCode: 93}
-	ColorBrightBlue    = Color{ansiCode: 94}
-	ColorBrightMagenta = Color{ansiCode: 95}
-	ColorBrightCyan    = Color{ansiCode: 96}
-	ColorBrightWhite   = Color{ansiCode: 97}
+	ColorBrightBlack   = ansiColor(90)
+	ColorBrightRed     = ansiColor(91)
+	ColorBrightGreen   = ansiColor(92)
+	ColorBrightYellow  = ansiColor(93)
+	ColorBrightBlue    = ansiColor(94)
+	ColorBrightMagenta = ansiColor(95)
+	ColorBrightCyan    = ansiColor(96)
+	ColorBrightWhite   = ansiColor(97)
 )
 
 // RGB creates a true color from red, green, and blue components (0-255).
 func RGB(r, g, b uint8) Color {
-	return Color{r: r, g: g, b: b, isTrueColor: true}
+	return Color{tone: tone{r: r, g: g, b: b, isTrueColor: true}}
 }
 
-// Hex creates a true color from a hex color string (e.g., "#FF5733" or "FF5733").
-// Returns ColorDefault if the input is invalid.
+// Hex creates a true color from a hex color string (e.g., "#FF5733",
+// "FF5733" or "#F53"). Returns ColorDefault if the input is invalid; use
+// ParseColor to find out why.
 func Hex(hex string) Color {
-	if len(hex) > 0 && hex[0] == '#' {
-		hex = hex[1:]
+	if len(hex) == 0 || hex[0] != '#' {
+		hex = "#" + hex
 	}
-	if len(hex) != 6 {
-		return ColorDefault
-	}
-	var r, g, b uint8
-	_, err := fmt.Sscanf(hex, "%02x%02x%02x", &r, &g, &b)
+	t, err := parseHex(hex)
 	if err != nil {
 		return ColorDefault
 	}
-	return RGB(r, g, b)
+	return Color{tone: t}
+}
+
+// Color256 creates a color from the xterm 256-color palette: 0-15 are the
+// ANSI colors, 16-231 a 6x6x6 color cube (see Cube) and 232-255 a grayscale
+// ramp (see Grayscale).
+func Color256(n uint8) Color {
+	return Color{tone: tone{index: n, isIndexed: true}}
+}
+
+// Cube creates a color from the 6x6x6 cube of the 256-color palette, with
+// red, green and blue levels from 0 to 5. Larger levels are treated as 5.
+func Cube(r, g, b uint8) Color {
+	r, g, b = min(r, 5), min(g, 5), min(b, 5)
+	return Color256(16 + 36*r + 6*g + b)
+}
+
+// Grayscale creates a color from the grayscale ramp of the 256-color
+// palette, from 0 (nearly black) to 23 (nearly white). Larger levels are
+// treated as 23.
+func Grayscale(level uint8) Color {
+	return Color256(232 + min(level, 23))
+}
+
+// On returns c with its background set to bg's foreground color. Text
+// attributes and the background of bg are ignored.
+func (c Color) On(bg Color) Color {
+	c.bg = bg.tone
+	return c
+}
+
+// Bold returns c with bold (increased intensity) text.
+func (c Color) Bold() Color { return c.with(attrBold) }
+
+// Dim returns c with dim (decreased intensity) text.
+func (c Color) Dim() Color { return c.with(attrDim) }
+
+// Italic returns c with italic text. Not every terminal supports it.
+func (c Color) Italic() Color { return c.with(attrItalic) }
+
+// Underline returns c with underlined text.
+func (c Color) Underline() Color { return c.with(attrUnderline) }
+
+// Strikethrough returns c with text struck through. Not every terminal
+// supports it.
+func (c Color) Strikethrough() Color { return c.with(attrStrikethrough) }
+
+// Reverse returns c with its foreground and background colors swapped.
+func (c Color) Reverse() Color { return c.with(attrReverse) }
+
+// with returns c with the attributes a added.
+func (c Color) with(a attr) Color {
+	c.attrs |= a
+	return c
 }
 
-// isDefault returns true if this is the default (unset) color.
+// isDefault returns true if this is the default (unset) color: no
+// foreground, no background and no attributes.
 func (c Color) isDefault() bool {
-	return !c.isTrueColor && c.ansiCode == 0
+	return c.tone.isDefault() && c.bg.isDefault() && c.attrs == 0
+}
+
+// rgb returns the RGB value t is displayed as, using the xterm values for
+// ANSI and palette colors. The default tone is reported as black.
+func (t tone) rgb() (r, g, b uint8) {
+	switch {
+	case t.isTrueColor:
+		return t.r, t.g, t.b
+	case t.isIndexed:
+		return paletteRGB(t.index)
+	case t.ansiCode >= 90:
+		return paletteRGB(uint8(t.ansiCode - 90 + 8))
+	case t.ansiCode >= 30:
+		return paletteRGB(uint8(t.ansiCode - 30))
+	}
+	return 0, 0, 0
+}
+
+// luminance returns the relative luminance of t as defined by WCAG 2, from
+// 0 for black to 1 for white.
+func (t tone) luminance() float64 {
+	r, g, b := t.rgb()
+	return 0.2126*toLinear(r) + 0.7152*toLinear(g) + 0.0722*toLinear(b)
+}
+
+// contrastRatio returns the WCAG 2 contrast ratio between two luminances,
+// from 1 (no contrast) to 21 (black on white).
+func contrastRatio(l1, l2 float64) float64 {
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05)
+}
+
+// readableOn returns black or white, whichever contrasts more with the
+// background bg.
+func readableOn(bg tone) Color {
+	l := bg.luminance()
+	if contrastRatio(l, 0) >= contrastRatio(l, 1) {
+		return RGB(0, 0, 0)
+	}
+	return RGB(255, 255, 255)
+}
+
+// isDefault returns true if t is unset.
+func (t tone) isDefault() bool {
+	return !t.isTrueColor && !t.isIndexed && t.ansiCode == 0
 }
 
-// apply wraps text with the appropriate ANSI escape codes.
-// If the color is default or colorEnabled is false, the text is returned unchanged.
+// apply wraps text with the appropriate ANSI escape codes, at full color
+// fidelity. If the color is default or colorEnabled is false, the text is
+// returned unchanged. Output renders through paint instead, which adapts
+// the codes to the terminal's color profile.
 func (c Color) apply(text string, colorEnabled bool) string {
-	if !colorEnabled || c.isDefault() {
+	if !colorEnabled {
+		return c.render(text, ProfileNone)
+	}
+	return c.render(text, ProfileTrueColor)
+}
+
+// render wraps text with the escape codes for c in profile p, downsampling
+// true colors that p cannot show, and resets all styling after it. If the
+// color is default or p is ProfileNone, the text is returned unchanged.
+func (c Color) render(text string, p ColorProfile) string {
+	if p == ProfileNone || c.isDefault() {
 		return text
 	}
-	if c.isTrueColor {
-		return fmt.Sprintf("\033[38;2;%d;%d;%dm%s\033[0m", c.r, c.g, c.b, text)
+	return fmt.Sprintf("\033[%sm%s\033[0m", c.sgr(p), text)
+}
+
+// sgr returns the SGR parameters that select c's attributes and colors in
+// profile p, as a single sequence.
+func (c Color) sgr(p ColorProfile) string {
+	var params []string
+	for _, ac := range attrCodes {
+		if c.attrs&ac.a != 0 {
+			params = append(params, strconv.Itoa(ac.code))
+		}
+	}
+	if !c.tone.isDefault() {
+		params = append(params, c.tone.sgr(p, false))
+	}
+	if !c.bg.isDefault() {
+		params = append(params, c.bg.sgr(p, true))
+	}
+	return strings.Join(params, ";")
+}
+
+// sgr returns the SGR parameters that select t as the foreground color, or
+// the background color if background is set, in profile p.
+func (t tone) sgr(p ColorProfile, background bool) string {
+	extended, offset := "38", 0
+	if background {
+		extended, offset = "48", 10
+	}
+	if t.isIndexed {
+		if p >= ProfileANSI256 {
+			return fmt.Sprintf("%s;5;%d", extended, t.index)
+		}
+		if t.index < 16 {
+			return strconv.Itoa(ansiCode(int(t.index)) + offset)
+		}
+		r, g, b := paletteRGB(t.index)
+		return strconv.Itoa(rgbTo16(r, g, b) + offset)
+	}
+	if !t.isTrueColor {
+		return strconv.Itoa(t.ansiCode + offset)
+	}
+	switch p {
+	case ProfileTrueColor:
+		return fmt.Sprintf("%s;2;%d;%d;%d", extended, t.r, t.g, t.b)
+	case ProfileANSI256:
+		return fmt.Sprintf("%s;5;%d", extended, rgbTo256(t.r, t.g, t.b))
+	default:
+		return strconv.Itoa(rgbTo16(t.r, t.g, t.b) + offset)
 	}
-	return fmt.Sprintf("\033[%dm%s\033[0m", c.ansiCode, text)
 }
@@ -0,0 +1,182 @@
+package cliout
+
+import "math"
+
+// Lighten returns c with its lightness raised by amount, from 0 (no change)
+// to 1 (white), as in CSS preprocessors: Lighten(0.1) turns a lightness of
+// 40% into 50%. The result is a true color; ANSI and palette colors are
+// converted using the xterm RGB values. The default color is returned
+// unchanged, as its real color is up to the terminal. Background and text
+// attributes are kept. The same applies to the other color manipulation
+// methods.
+func (c Color) Lighten(amount float64) Color {
+	return c.adjustHSL(func(h, s, l float64) (float64, float64, float64) {
+		return h, s, l + amount
+	})
+}
+
+// Darken returns c with its lightness lowered by amount, from 0 (no
+// change) to 1 (black). See Lighten.
+func (c Color) Darken(amount float64) Color {
+	return c.Lighten(-amount)
+}
+
+// Saturate returns c with its saturation raised by amount, from 0 (no
+// change) to 1 (fully saturated). See Lighten.
+func (c Color) Saturate(amount float64) Color {
+	return c.adjustHSL(func(h, s, l float64) (float64, float64, float64) {
+		return h, s + amount, l
+	})
+}
+
+// Desaturate returns c with its saturation lowered by amount, from 0 (no
+// change) to 1 (gray). See Lighten.
+func (c Color) Desaturate(amount float64) Color {
+	return c.Saturate(-amount)
+}
+
+// Complement returns the color opposite c on the color wheel, with the
+// same saturation and lightness. See Lighten.
+func (c Color) Complement() Color {
+	return c.adjustHSL(func(h, s, l float64) (float64, float64, float64) {
+		return h + 180, s, l
+	})
+}
+
+// Mix blends c with other, from weight 0 (all c) to 1 (all other). The
+// blend is done in the OKLab color space, so that the colors in between
+// change evenly to the eye rather than turning muddy as they can when RGB
+// values are averaged. If either color is the default color, c is returned
+// unchanged. Background and text attributes are taken from c. See Lighten.
+func (c Color) Mix(other Color, weight float64) Color {
+	if c.tone.isDefault() || other.tone.isDefault() {
+		return c
+	}
+	weight = clamp01(weight)
+	l1, a1, b1 := toOKLab(c.tone.rgb())
+	l2, a2, b2 := toOKLab(other.tone.rgb())
+	lerp := func(x, y float64) float64 { return x + (y-x)*weight }
+	return c.withRGB(fromOKLab(lerp(l1, l2), lerp(a1, a2), lerp(b1, b2)))
+}
+
+// adjustHSL returns c with its foreground changed by f, which is given and
+// returns the hue in degrees and the saturation and lightness from 0 to 1.
+// Out-of-range results are clamped.
+func (c Color) adjustHSL(f func(h, s, l float64) (float64, float64, float64)) Color {
+	if c.tone.isDefault() {
+		return c
+	}
+	h, s, l := f(rgbToHSL(c.tone.rgb()))
+	return c.withRGB(hslToRGB(h, clamp01(s), clamp01(l)))
+}
+
+// withRGB returns c with its foreground replaced by a true color.
+func (c Color) withRGB(r, g, b uint8) Color {
+	c.tone = RGB(r, g, b).tone
+	return c
+}
+
+// rgbToHSL converts RGB to a hue in degrees and a saturation and lightness
+// from 0 to 1.
+func rgbToHSL(r, g, b uint8) (h, s, l float64) {
+	rf, gf, bf := float64(r)/255, float64(g)/255, float64(b)/255
+	hi, lo := max(rf, gf, bf), min(rf, gf, bf)
+	l = (hi + lo) / 2
+	d := hi - lo
+	if d == 0 {
+		return 0, 0, l
+	}
+	s = d / (1 - math.Abs(2*l-1))
+	switch hi {
+	case rf:
+		h = math.Mod((gf-bf)/d, 6)
+	case gf:
+		h = (bf-rf)/d + 2
+	default:
+		h = (rf-gf)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, l
+}
+
+// hslToRGB converts a hue in degrees and a saturation and lightness from 0
+// to 1 to RGB.
+func hslToRGB(h, s, l float64) (r, g, b uint8) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	chroma := (1 - math.Abs(2*l-1)) * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - chroma/2
+	var rf, gf, bf float64
+	switch {
+	case h < 60:
+		rf, gf, bf = chroma, x, 0
+	case h < 120:
+		rf, gf, bf = x, chroma, 0
+	case h < 180:
+		rf, gf, bf = 0, chroma, x
+	case h < 240:
+		rf, gf, bf = 0, x, chroma
+	case h < 300:
+		rf, gf, bf = x, 0, chroma
+	default:
+		rf, gf, bf = chroma, 0, x
+	}
+	return to8(rf + m), to8(gf + m), to8(bf + m)
+}
+
+// toOKLab converts sRGB to OKLab lightness and a, b components.
+// See https://bottosson.github.io/posts/oklab/.
+func toOKLab(r, g, b uint8) (l, a, bb float64) {
+	rl, gl, bl := toLinear(r), toLinear(g), toLinear(b)
+	lc := math.Cbrt(0.4122214708*rl + 0.5363325363*gl + 0.0514459929*bl)
+	mc := math.Cbrt(0.2119034982*rl + 0.6806995451*gl + 0.1073969566*bl)
+	sc := math.Cbrt(0.0883024619*rl + 0.2817188376*gl + 0.6299787005*bl)
+	return 0.2104542553*lc + 0.7936177850*mc - 0.0040720468*sc,
+		1.9779984951*lc - 2.4285922050*mc + 0.4505937099*sc,
+		0.0259040371*lc + 0.7827717662*mc - 0.8086757660*sc
+}
+
+// fromOKLab converts OKLab to sRGB, clipping colors outside the sRGB gamut.
+func fromOKLab(l, a, bb float64) (r, g, b uint8) {
+	lc := l + 0.3963377774*a + 0.2158037573*bb
+	mc := l - 0.1055613458*a - 0.0638541728*bb
+	sc := l - 0.0894841775*a - 1.2914855480*bb
+	lc, mc, sc = lc*lc*lc, mc*mc*mc, sc*sc*sc
+	return fromLinear(4.0767416621*lc - 3.3077115913*mc + 0.2309699292*sc),
+		fromLinear(-1.2684380046*lc + 2.6097574011*mc - 0.3413193965*sc),
+		fromLinear(-0.0041960863*lc - 0.7034186147*mc + 1.7076147010*sc)
+}
+
+// toLinear converts an sRGB component to linear light from 0 to 1.
+func toLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// fromLinear converts linear light to an sRGB component.
+func fromLinear(c float64) uint8 {
+	c = clamp01(c)
+	if c <= 0.0031308 {
+		return to8(12.92 * c)
+	}
+	return to8(1.055*math.Pow(c, 1/2.4) - 0.055)
+}
+
+// to8 converts a component from 0 to 1 to 0-255, rounding and clamping.
+func to8(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 255))
+}
+
+// clamp01 limits v to the range 0 to 1.
+func clamp01(v float64) float64 {
+	return min(max(v, 0), 1)
+}
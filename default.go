@@ -41,17 +41,52 @@ func SetTheme(t Theme) {
 	defaultOutput.SetTheme(t)
 }
 
+// SetFormat sets the output format on the default output.
+func SetFormat(f Format) {
+	defaultOutput.SetFormat(f)
+}
+
 // SetColorEnabled enables or disables color on the default output.
 func SetColorEnabled(enabled bool) {
 	defaultOutput.SetColorEnabled(enabled)
 }
 
+// SetColorProfile sets the color profile of the default output.
+// See Output.SetColorProfile.
+func SetColorProfile(p ColorProfile) {
+	defaultOutput.SetColorProfile(p)
+}
+
+// SetWidth sets the width messages are wrapped to on the default output.
+// See Output.SetWidth.
+func SetWidth(n int) {
+	defaultOutput.SetWidth(n)
+}
+
+// SetMultilineStyle sets how later lines of multi-line messages are
+// introduced on the default output.
+func SetMultilineStyle(s MultilineStyle) {
+	defaultOutput.SetMultilineStyle(s)
+}
+
 // Colorize wraps text with the given color, respecting the default output's
 // color-enabled setting.
 func Colorize(text string, c Color) string {
 	return defaultOutput.Colorize(text, c)
 }
 
+// Badge renders label as a colored pill on bg, respecting the default
+// output's color-enabled setting. See Output.Badge.
+func Badge(label string, bg Color) string {
+	return defaultOutput.Badge(label, bg)
+}
+
+// With returns a child of the default output that appends the given fields
+// to every message.
+func With(fields ...Field) *Output {
+	return defaultOutput.With(fields...)
+}
+
 // --- Package-level output functions ---
 
 // Info prints an info-level message.
@@ -123,3 +158,51 @@ func Success(msg string) {
 func Successf(format string, a ...any) {
 	defaultOutput.Successf(format, a...)
 }
+
+// --- Package-level prompts ---
+
+// Confirm asks a yes/no question on the default output. See Output.Confirm.
+func Confirm(question string, def bool) (bool, error) {
+	return defaultOutput.Confirm(question, def)
+}
+
+// Select asks the user to choose one option on the default output. See
+// Output.Select.
+func Select(question string, options []string) (int, error) {
+	return defaultOutput.Select(question, options)
+}
+
+// MultiSelect asks the user to choose any number of options on the default
+// output. See Output.MultiSelect.
+func MultiSelect(question string, options []string) ([]int, error) {
+	return defaultOutput.MultiSelect(question, options)
+}
+
+// Input asks for a line of text on the default output. See Output.Input.
+func Input(question string, opts InputOptions) (string, error) {
+	return defaultOutput.Input(question, opts)
+}
+
+// Password asks for a secret on the default output. See Output.Password.
+func Password(question string, opts InputOptions) (string, error) {
+	return defaultOutput.Password(question, opts)
+}
+
+// Form fills in a struct by prompting on the default output. See Output.Form.
+func Form(v any) error {
+	return defaultOutput.Form(v)
+}
+
+// --- Package-level groups ---
+
+// SetGroupStyle sets how the lines of groups are indented on the default
+// output.
+func SetGroupStyle(s GroupStyle) {
+	defaultOutput.SetGroupStyle(s)
+}
+
+// Group prints a heading on the default output and returns a child whose
+// lines are indented below it. See Output.Group.
+func Group(title string) *Output {
+	return defaultOutput.Group(title)
+}
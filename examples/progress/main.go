@@ -0,0 +1,56 @@
+// Example: Progress bars
+//
+// Demonstrates a counted progress bar and a byte-counting bar driven by
+// io.Copy through a wrapped reader. When stdout is not a terminal the bars
+// print throttled textual updates instead of redrawing in place.
+//
+// Run:
+//
+//	go run ./examples/progress/
+//	go run ./examples/progress/ | cat
+package main
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/z0mbix/cliout"
+)
+
+func main() {
+	out := cliout.Default()
+	out.SetTheme(cliout.ThemeTokyoNightStorm)
+
+	// --- Counted progress ---
+	files := 40
+	p := out.Progress("processing files", int64(files))
+	for i := 0; i < files; i++ {
+		time.Sleep(50 * time.Millisecond)
+		p.Add(1)
+	}
+	p.Successf("processed %d files", files)
+
+	// --- Byte progress via io.Copy ---
+	const size = 4 << 20
+	src := &slowReader{r: strings.NewReader(strings.Repeat("x", size))}
+	p = out.Progress("downloading release.tar.gz", size)
+	if _, err := io.Copy(io.Discard, p.Reader(src)); err != nil {
+		p.Errorf("download failed: %v", err)
+		return
+	}
+	p.Success("downloaded release.tar.gz")
+}
+
+// slowReader throttles reads to simulate a network download.
+type slowReader struct {
+	r io.Reader
+}
+
+func (s *slowReader) Read(b []byte) (int, error) {
+	time.Sleep(20 * time.Millisecond)
+	if len(b) > 64<<10 {
+		b = b[:64<<10]
+	}
+	return s.r.Read(b)
+}
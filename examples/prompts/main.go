@@ -0,0 +1,78 @@
+// Example: Prompts
+//
+// Demonstrates text input with validation, masked password entry, selection
+// menus and a confirmation prompt. Run it in a terminal; without one, the
+// name and token can be supplied with PROJECT_NAME and REGISTRY_TOKEN.
+//
+// Run: go run ./examples/prompts/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/z0mbix/cliout"
+)
+
+func main() {
+	out := cliout.Default()
+	out.SetTheme(cliout.ThemeDracula)
+
+	name, err := out.Input("Project name", cliout.InputOptions{
+		Default: "my-app",
+		Env:     "PROJECT_NAME",
+		Validate: func(s string) error {
+			if strings.ContainsAny(s, " /") {
+				return errors.New("name must not contain spaces or slashes")
+			}
+			return nil
+		},
+	})
+	check(out, err)
+
+	token, err := out.Password("Registry token", cliout.InputOptions{
+		Env: "REGISTRY_TOKEN",
+		Validate: func(s string) error {
+			if len(s) < 8 {
+				return errors.New("token must be at least 8 characters")
+			}
+			return nil
+		},
+	})
+	check(out, err)
+
+	regions := []string{"eu-west-1", "eu-central-1", "us-east-1", "us-west-2", "ap-southeast-1"}
+	region, err := out.Select("Region", regions)
+	check(out, err)
+
+	features := []string{"metrics", "tracing", "logging", "alerts"}
+	enabled, err := out.MultiSelect("Features", features)
+	check(out, err)
+
+	ok, err := out.Confirm(fmt.Sprintf("Create %s in %s?", name, regions[region]), true)
+	check(out, err)
+	if !ok {
+		out.Warn("Aborted")
+		return
+	}
+
+	chosen := make([]string, len(enabled))
+	for i, idx := range enabled {
+		chosen[i] = features[idx]
+	}
+	out.Successf("Created %s in %s with %d-character token", name, regions[region], len(token))
+	out.Infof("Features: %s", strings.Join(chosen, ", "))
+}
+
+func check(out *cliout.Output, err error) {
+	if err == nil {
+		return
+	}
+	if errors.Is(err, cliout.ErrInterrupted) {
+		out.Warn("Cancelled")
+		os.Exit(130)
+	}
+	out.Fatal(err.Error())
+}
@@ -1,8 +1,8 @@
 // Example: Real-world CLI application
 //
 // Simulates a realistic deployment tool that uses cliout for user-facing
-// output. Demonstrates how levels, themes, prefixes, and format strings
-// work together in a practical scenario.
+// output. Demonstrates how levels, themes, prefixes, format strings, groups
+// and multi-progress displays work together in a practical scenario.
 //
 // Run:
 //
@@ -36,52 +36,65 @@ func main() {
 	cliout.Debugf("kubernetes context: %s", "prod-cluster-eu-west-1")
 
 	// --- Validation ---
-	cliout.Info("validating configuration")
+	validate := cliout.Group("validating configuration")
 	sleep()
-	cliout.Debug("checking required fields")
-	cliout.Debug("validating service definitions")
-	cliout.Warnf("service %q has no resource limits defined", "api-gateway")
-	cliout.Debug("validation complete")
+	validate.Debug("checking required fields")
+	validate.Debug("validating service definitions")
+	validate.Warnf("service %q has no resource limits defined", "api-gateway")
+	validate.Debug("validation complete")
+	validate.End()
 
 	// --- Build ---
-	cliout.Info("building container images")
+	build := cliout.Group("building container images")
 
 	services := []string{"api-gateway", "auth-service", "worker"}
+	builds := build.MultiProgress()
 	for i, svc := range services {
-		cliout.Infof("building %s (%d/%d)", svc, i+1, len(services))
-		sleep()
-		cliout.Debugf("docker build -t registry.example.com/%s:v1.2.3 .", svc)
-		sleep()
+		s := builds.Spinner(fmt.Sprintf("building %s", svc))
+		go func(i int, svc string) {
+			for step := 1; step <= 3+i; step++ {
+				sleep()
+				s.Updatef("building %s (step %d/%d)", svc, step, 3+i)
+			}
+			build.Debugf("docker build -t registry.example.com/%s:v1.2.3 .", svc)
+			s.Successf("built %s", svc)
+		}(i, svc)
 	}
-	cliout.Successf("built %d images", len(services))
+	builds.Wait()
+	build.Successf("built %d images", len(services))
+	build.End()
 
 	// --- Push ---
-	cliout.Info("pushing images to registry")
+	push := cliout.Group("pushing images to registry")
 	for _, svc := range services {
-		cliout.Debugf("pushing registry.example.com/%s:v1.2.3", svc)
+		push.Debugf("pushing registry.example.com/%s:v1.2.3", svc)
 		sleep()
 	}
-	cliout.Success("all images pushed")
+	push.Success("all images pushed")
+	push.End()
 
 	// --- Deploy ---
-	cliout.Info("deploying to cluster")
+	deploy := cliout.Group("deploying to cluster")
 	for _, svc := range services {
-		cliout.Infof("rolling out %s", svc)
+		rollout := deploy.Group(fmt.Sprintf("rolling out %s", svc))
 		sleep()
-		cliout.Debugf("kubectl apply -f k8s/%s/deployment.yaml", svc)
+		rollout.Debugf("kubectl apply -f k8s/%s/deployment.yaml", svc)
 		sleep()
+		rollout.End()
 	}
+	deploy.End()
 
 	// --- Health checks ---
-	cliout.Info("running health checks")
+	health := cliout.Group("running health checks")
 	for i, svc := range services {
 		sleep()
 		if i == 1 {
-			cliout.Warnf("%s: health check slow (1200ms)", svc)
+			health.Warnf("%s: health check slow (1200ms)", svc)
 		} else {
-			cliout.Debugf("%s: healthy (45ms)", svc)
+			health.Debugf("%s: healthy (45ms)", svc)
 		}
 	}
+	health.End()
 
 	// --- Summary ---
 	fmt.Println()
@@ -0,0 +1,38 @@
+// Example: Spinners
+//
+// Demonstrates animated spinners that finish with a themed result line.
+// When stdout is not a terminal (e.g. piped to a file) the spinner degrades
+// to a single start line and a single end line.
+//
+// Run:
+//
+//	go run ./examples/spinner/
+//	go run ./examples/spinner/ | cat
+package main
+
+import (
+	"time"
+
+	"github.com/z0mbix/cliout"
+)
+
+func main() {
+	out := cliout.Default()
+	out.SetTheme(cliout.ThemeCatppuccinoMocha)
+
+	s := out.Spinner("resolving dependencies")
+	time.Sleep(1 * time.Second)
+	s.Update("downloading 12 modules")
+	time.Sleep(1 * time.Second)
+	s.Success("dependencies ready")
+
+	s = out.Spinner("checking for updates")
+	time.Sleep(800 * time.Millisecond)
+	out.Info("log lines printed while a spinner runs appear above it")
+	time.Sleep(800 * time.Millisecond)
+	s.Warn("2 modules have newer versions")
+
+	s = out.Spinner("connecting to registry")
+	time.Sleep(1 * time.Second)
+	s.Error("registry unreachable")
+}
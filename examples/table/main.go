@@ -0,0 +1,49 @@
+// Example: Tables
+//
+// Demonstrates themed tables with column alignment, per-cell colours and
+// the three border styles.
+//
+// Run: go run ./examples/table/
+package main
+
+import (
+	"fmt"
+
+	"github.com/z0mbix/cliout"
+)
+
+type service struct {
+	name, status, version string
+	replicas              int
+}
+
+func main() {
+	out := cliout.Default()
+	out.SetTheme(cliout.ThemeNord)
+
+	services := []service{
+		{"api-gateway", "healthy", "v1.2.3", 3},
+		{"auth-service", "degraded", "v1.2.3", 2},
+		{"worker", "healthy", "v1.1.0", 12},
+		{"scheduler", "failing", "v0.9.8", 1},
+	}
+
+	for _, border := range []cliout.BorderStyle{cliout.BorderNone, cliout.BorderASCII, cliout.BorderUnicode} {
+		t := out.Table("SERVICE", "STATUS", "VERSION", "REPLICAS")
+		t.SetBorder(border)
+		t.SetAlign(3, cliout.AlignRight)
+		for i, svc := range services {
+			t.AddRow(svc.name, svc.status, svc.version, fmt.Sprint(svc.replicas))
+			switch svc.status {
+			case "healthy":
+				t.SetCellColor(i, 1, cliout.ColorGreen)
+			case "degraded":
+				t.SetCellColor(i, 1, cliout.ColorYellow)
+			default:
+				t.SetCellColor(i, 1, cliout.ColorRed)
+			}
+		}
+		t.Render()
+		fmt.Println()
+	}
+}
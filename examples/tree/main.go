@@ -0,0 +1,45 @@
+// Example: Trees
+//
+// Demonstrates printing hierarchical data with themed branches, per-node
+// levels and both glyph styles.
+//
+// Run:
+//
+//	go run ./examples/tree/
+//	go run ./examples/tree/ -verbose
+package main
+
+import (
+	"flag"
+
+	"github.com/z0mbix/cliout"
+)
+
+func main() {
+	verbose := flag.Bool("verbose", false, "show indirect dependencies")
+	flag.Parse()
+
+	out := cliout.Default()
+	out.SetTheme(cliout.ThemeGruvboxDark)
+	if *verbose {
+		out.SetLevel(cliout.LevelDebug)
+	}
+
+	root := cliout.Node("github.com/example/deploy")
+	k8s := root.Add("k8s.io/client-go v0.29.0")
+	for _, dep := range []string{"k8s.io/api v0.29.0", "k8s.io/apimachinery v0.29.0"} {
+		indirect := k8s.Add(dep)
+		indirect.Level = cliout.LevelDebug
+	}
+	root.Add("github.com/spf13/cobra v1.8.0")
+	old := root.Add("github.com/pkg/errors v0.9.1 (deprecated)")
+	old.Level = cliout.LevelWarn
+	missing := root.Add("github.com/example/internal (not found)")
+	missing.Level = cliout.LevelError
+
+	out.Info("dependency tree:")
+	out.Tree(root)
+
+	out.Info("ascii style:")
+	out.TreeWithStyle(root, cliout.TreeASCII)
+}
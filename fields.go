@@ -0,0 +1,80 @@
+package cliout
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Field is a key/value pair attached to a message with With. Fields are
+// rendered after the message as key=value, with keys in the theme's
+// PrefixColor and values in its DebugColor.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// F creates a Field.
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+// With returns a child Output that appends the given fields to every
+// message it prints, after any fields already attached to o. The child
+// shares o's configuration, writers and lock: setters called on either
+// affect both, and their lines never interleave.
+func (o *Output) With(fields ...Field) *Output {
+	merged := make([]Field, 0, len(o.fields)+len(fields))
+	merged = append(merged, o.fields...)
+	merged = append(merged, fields...)
+	return &Output{outputState: o.outputState, fields: merged, group: o.group}
+}
+
+// renderFields returns fields as " key=value key=value", or an empty string
+// if there are none. The caller must hold o.mu.
+func (o *Output) renderFields(fields []Field, colorEnabled bool) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	keyColor := o.currentPrefixColor()
+	valueColor := o.theme.DebugColor
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteByte(' ')
+		b.WriteString(o.paint(keyColor, f.Key, colorEnabled))
+		b.WriteByte('=')
+		b.WriteString(o.paint(valueColor, quoteIfNeeded(formatValue(f.Value)), colorEnabled))
+	}
+	return b.String()
+}
+
+// formatValue returns the text form of a field value.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// quoteIfNeeded quotes s when it would be ambiguous unquoted: when it is
+// empty, or contains whitespace, quotes, '=' or non-printable characters.
+func quoteIfNeeded(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,288 @@
+package cliout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// errRequired is shown when a required form field is left empty.
+var errRequired = errors.New("a value is required")
+
+// Form fills in the fields of the struct pointed to by v by prompting for
+// each exported field that has a prompt tag, in declaration order. Other
+// fields are left alone. The tags are:
+//
+//	prompt   the question to ask (required for the field to be asked)
+//	default  the answer used when the user enters nothing
+//	required "true" to reject empty answers
+//	choices  comma-separated options, asked with Select (or MultiSelect
+//	         for a []string field)
+//	secret   "true" to ask with Password
+//	env      an environment variable that pre-fills the answer
+//
+// For example:
+//
+//	type initOptions struct {
+//		Name    string   `prompt:"Project name" default:"my-app" required:"true"`
+//		Region  string   `prompt:"Region" choices:"eu-west-1,us-east-1" env:"REGION"`
+//		Token   string   `prompt:"API token" secret:"true" env:"API_TOKEN"`
+//		Workers int      `prompt:"Workers" default:"4"`
+//		Modules []string `prompt:"Modules" choices:"api,web,cli"`
+//		Public  bool     `prompt:"Public?"`
+//	}
+//
+// Fields may be strings, booleans (asked with Confirm), integers, floats or,
+// with choices, []string. A field's current value is used as its default
+// when it has no default tag. When an env variable is set, its value
+// replaces the default; this lets CI runs pre-fill a form.
+//
+// Invalid answers are reported in the theme's ErrorColor and asked again.
+// When the input is not a terminal nothing is asked: each field takes its
+// pre-filled value or default, and Form returns an error naming the first
+// field that is missing a required value or whose value is invalid.
+func (o *Output) Form(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cliout: Form needs a non-nil pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	o.promptMu.Lock()
+	interactive := o.interactive()
+	o.promptMu.Unlock()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		ff, err := parseFormField(sf)
+		if err != nil {
+			return err
+		}
+		if ff == nil {
+			continue
+		}
+		fv := rv.Field(i)
+		if ff.def == "" {
+			ff.def = currentValue(fv)
+		}
+		if ff.env != "" {
+			if val, ok := os.LookupEnv(ff.env); ok {
+				ff.def = val
+			}
+		}
+		if interactive {
+			err = o.askFormField(ff, fv)
+		} else {
+			err = ff.fill(fv, ff.def)
+		}
+		if err != nil {
+			return fmt.Errorf("cliout: %s: %w", ff.label(), err)
+		}
+	}
+	return nil
+}
+
+// formField is the parsed tags of one struct field.
+type formField struct {
+	name     string
+	prompt   string
+	def      string
+	env      string
+	choices  []string
+	required bool
+	secret   bool
+	multi    bool
+}
+
+// parseFormField reads the tags of sf, returning nil if it is not asked.
+func parseFormField(sf reflect.StructField) (*formField, error) {
+	prompt, ok := sf.Tag.Lookup("prompt")
+	if !ok || !sf.IsExported() {
+		return nil, nil
+	}
+	ff := &formField{
+		name:     sf.Name,
+		prompt:   prompt,
+		def:      sf.Tag.Get("default"),
+		env:      sf.Tag.Get("env"),
+		required: sf.Tag.Get("required") == "true",
+		secret:   sf.Tag.Get("secret") == "true",
+	}
+	if c := sf.Tag.Get("choices"); c != "" {
+		ff.choices = splitList(c)
+	}
+
+	switch sf.Type.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	case reflect.Slice:
+		if sf.Type.Elem().Kind() != reflect.String || len(ff.choices) == 0 {
+			return nil, fmt.Errorf("cliout: field %s: slices need string elements and a choices tag", sf.Name)
+		}
+		ff.multi = true
+	default:
+		return nil, fmt.Errorf("cliout: field %s: unsupported type %s", sf.Name, sf.Type)
+	}
+	return ff, nil
+}
+
+// label names the field in errors, pointing at its env variable if it has
+// one.
+func (ff *formField) label() string {
+	if ff.env != "" {
+		return fmt.Sprintf("%s (%s)", ff.name, ff.env)
+	}
+	return ff.name
+}
+
+// askFormField prompts for one field and stores the answer in fv.
+func (o *Output) askFormField(ff *formField, fv reflect.Value) error {
+	switch {
+	case len(ff.choices) > 0:
+		var defaults []int
+		for _, d := range splitList(ff.def) {
+			for i, c := range ff.choices {
+				if c == d {
+					defaults = append(defaults, i)
+				}
+			}
+		}
+		for {
+			chosen, err := o.choose(ff.prompt, ff.choices, ff.multi, defaults)
+			if err != nil {
+				return err
+			}
+			if ff.required && len(chosen) == 0 {
+				o.writePromptError("choose at least one option")
+				continue
+			}
+			names := make([]string, len(chosen))
+			for i, c := range chosen {
+				names[i] = ff.choices[c]
+			}
+			return ff.fill(fv, strings.Join(names, ","))
+		}
+
+	case fv.Kind() == reflect.Bool:
+		def, _ := strconv.ParseBool(ff.def)
+		answer, err := o.Confirm(ff.prompt, def)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(answer)
+		return nil
+
+	default:
+		opts := InputOptions{
+			Default: ff.def,
+			Validate: func(s string) error {
+				return ff.check(fv, s)
+			},
+		}
+		ask := o.Input
+		if ff.secret {
+			ask = o.Password
+		}
+		answer, err := ask(ff.prompt, opts)
+		if err != nil {
+			return err
+		}
+		return ff.fill(fv, answer)
+	}
+}
+
+// check validates s as a value for the field without storing it.
+func (ff *formField) check(fv reflect.Value, s string) error {
+	return ff.fill(reflect.New(fv.Type()).Elem(), s)
+}
+
+// fill validates s and stores it in fv. Lists of choices are
+// comma-separated.
+func (ff *formField) fill(fv reflect.Value, s string) error {
+	if s == "" {
+		if ff.required {
+			return errRequired
+		}
+		fv.SetZero()
+		return nil
+	}
+	if len(ff.choices) > 0 {
+		values := splitList(s)
+		if !ff.multi && len(values) != 1 {
+			return fmt.Errorf("%q is not one of %s", s, strings.Join(ff.choices, ", "))
+		}
+		for _, v := range values {
+			if !slices.Contains(ff.choices, v) {
+				return fmt.Errorf("%q is not one of %s", v, strings.Join(ff.choices, ", "))
+			}
+		}
+		if ff.multi {
+			fv.Set(reflect.ValueOf(values).Convert(fv.Type()))
+			return nil
+		}
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a non-negative whole number", s)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		fv.SetFloat(f)
+	}
+	return nil
+}
+
+// currentValue returns the text form of fv, or "" if it is the zero value.
+func currentValue(fv reflect.Value) string {
+	if fv.IsZero() {
+		return ""
+	}
+	if fv.Kind() == reflect.Slice {
+		items := make([]string, fv.Len())
+		for i := range items {
+			items[i] = fv.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(fv.Interface())
+}
+
+// splitList splits a comma-separated list, trimming spaces and dropping
+// empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,122 @@
+package cliout
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Format selects how an Output renders messages.
+type Format int
+
+const (
+	// FormatText renders colored, prefixed lines for humans. This is the
+	// default.
+	FormatText Format = iota
+	// FormatJSON renders each message as a single JSON object per line for
+	// machine consumers.
+	FormatJSON
+)
+
+// String returns the name of the format, as accepted by CLI_FORMAT.
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+// parseFormat returns the format with the given name (case-insensitive) and
+// true, or FormatText and false if the name is not recognised.
+func parseFormat(name string) (Format, bool) {
+	switch toLower(strings.TrimSpace(name)) {
+	case "text":
+		return FormatText, true
+	case "json":
+		return FormatJSON, true
+	default:
+		return FormatText, false
+	}
+}
+
+// jsonLine renders a message as a JSON object without a trailing newline:
+//
+//	{"time":"...","level":"info","msg":"...","success":false,"fields":{...}}
+//
+// ANSI escape sequences (for example from Colorize) are stripped from the
+// message. Fields keep their order, except that a repeated key keeps only
+// its last occurrence.
+// Values that cannot be encoded as JSON are rendered as strings.
+func jsonLine(level Level, msg string, isSuccess bool, fields []Field) string {
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	writeJSON(&b, timeNow().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, stripANSI(msg))
+	b.WriteString(`,"success":`)
+	writeJSON(&b, isSuccess)
+
+	if len(fields) > 0 {
+		last := make(map[string]int, len(fields))
+		for i, f := range fields {
+			last[f.Key] = i
+		}
+		b.WriteString(`,"fields":{`)
+		first := true
+		for i, f := range fields {
+			if last[f.Key] != i {
+				continue
+			}
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			writeJSON(&b, f.Key)
+			b.WriteByte(':')
+			writeJSONValue(&b, f.Value)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// writeJSON writes the JSON encoding of v, which must be encodable.
+func writeJSON(b *strings.Builder, v any) {
+	data, _ := marshalJSON(v)
+	b.Write(data)
+}
+
+// writeJSONValue writes a field value. Errors are written as their message,
+// and values json cannot encode fall back to their text form.
+func writeJSONValue(b *strings.Builder, v any) {
+	if err, ok := v.(error); ok {
+		writeJSON(b, err.Error())
+		return
+	}
+	data, err := marshalJSON(v)
+	if err != nil {
+		writeJSON(b, formatValue(v))
+		return
+	}
+	b.Write(data)
+}
+
+// marshalJSON encodes v without escaping HTML characters, which would only
+// make messages harder to read.
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,74 @@
+package cliout
+
+import "strings"
+
+// GroupStyle selects how the lines of a group are indented.
+type GroupStyle int
+
+const (
+	// GroupIndent indents each level of nesting by two spaces. This is the
+	// default.
+	GroupIndent GroupStyle = iota
+	// GroupGuides draws a vertical guide line for each level of nesting, in
+	// the theme's PrefixColor.
+	GroupGuides
+)
+
+// group is one level of nesting created by Output.Group. Its fields are
+// guarded by the owning Output's lock.
+type group struct {
+	parent *group
+	closed bool
+}
+
+// SetGroupStyle sets how the lines of groups are indented. It affects every
+// Output sharing this one's configuration, including groups that are
+// already open.
+func (o *Output) SetGroupStyle(s GroupStyle) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.groupStyle = s
+}
+
+// Group prints title as a heading at info level, in the theme's
+// PrefixColor, and returns a child Output whose lines are indented below
+// it until End is called on the child. Groups nest: calling Group on a
+// child indents further. The child shares o's configuration, writers, lock
+// and fields like a child created with With, and its messages are still
+// filtered by level. In JSON mode the heading is emitted as an info message
+// and lines are not indented.
+func (o *Output) Group(title string) *Output {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.printRenderedLocked(LevelInfo, o.paint(o.currentPrefixColor(), title, o.colorFor(LevelInfo)))
+	return &Output{outputState: o.outputState, fields: o.fields, group: &group{parent: o.group}}
+}
+
+// End closes the group o was created by, so that its lines are no longer
+// indented for it. Calling End more than once, or on an Output that is not
+// a group, does nothing.
+func (o *Output) End() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.group != nil {
+		o.group.closed = true
+	}
+}
+
+// renderIndent returns the indentation for o's open groups. The caller must
+// hold o.mu.
+func (o *Output) renderIndent(colorEnabled bool) string {
+	depth := 0
+	for g := o.group; g != nil; g = g.parent {
+		if !g.closed {
+			depth++
+		}
+	}
+	if depth == 0 {
+		return ""
+	}
+	if o.groupStyle == GroupGuides {
+		return o.paint(o.currentPrefixColor(), strings.Repeat("│ ", depth), colorEnabled)
+	}
+	return strings.Repeat("  ", depth)
+}
@@ -0,0 +1,162 @@
+package cliout
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// InputOptions configures Input and Password.
+type InputOptions struct {
+	// Default is returned when the answer is empty. Input shows it in the
+	// hint; Password never does.
+	Default string
+	// Validate, if set, checks each answer (including the default). When it
+	// returns an error the message is shown in the theme's ErrorColor and
+	// the question is asked again.
+	Validate func(string) error
+	// Env names an environment variable that supplies the answer when the
+	// input is not a terminal, so scripts and CI jobs can answer prompts
+	// without a TTY. An empty value selects the default.
+	Env string
+}
+
+// Input asks for a line of text and returns it with surrounding whitespace
+// removed.
+//
+// If the input is not a terminal and opts.Env names a variable that is set,
+// its value is used without prompting; if it fails validation the error is
+// returned. Otherwise, if the input is a file that is not a terminal, Input
+// returns opts.Default and ErrNotInteractive.
+func (o *Output) Input(question string, opts InputOptions) (string, error) {
+	return o.ask(question, opts, false)
+}
+
+// Password asks for a secret like Input, without echoing it. On a terminal
+// each character is shown as "*" while the terminal is in raw mode, which is
+// always restored afterwards, including when the process receives SIGINT or
+// SIGTERM; Ctrl-C returns ErrInterrupted. Masked entry needs raw terminal
+// mode, which is implemented on Linux, macOS and the BSDs; elsewhere
+// Password returns an error rather than echo the secret. The answer is not
+// trimmed.
+func (o *Output) Password(question string, opts InputOptions) (string, error) {
+	return o.ask(question, opts, true)
+}
+
+// ask implements Input and Password.
+func (o *Output) ask(question string, opts InputOptions, secret bool) (string, error) {
+	o.promptMu.Lock()
+	defer o.promptMu.Unlock()
+
+	if opts.Env != "" && !o.inputIsTerminal() {
+		if v, ok := os.LookupEnv(opts.Env); ok {
+			answer := normalizeAnswer(v, opts, secret)
+			if opts.Validate != nil {
+				if err := opts.Validate(answer); err != nil {
+					return "", fmt.Errorf("cliout: %s: %w", opts.Env, err)
+				}
+			}
+			return answer, nil
+		}
+	}
+	if !o.interactive() {
+		return opts.Default, ErrNotInteractive
+	}
+	defer o.pauseLive()()
+
+	hint := ""
+	if !secret && opts.Default != "" {
+		hint = "[" + opts.Default + "]"
+	}
+	for {
+		o.writePrompt(question, hint)
+		var answer string
+		var err error
+		if f, ok := o.input().(*os.File); ok && secret && isTerminal(f) {
+			answer, err = o.readMasked(f)
+		} else {
+			answer, err = o.readLine(secret)
+			if err != nil {
+				o.endPrompt()
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		answer = normalizeAnswer(answer, opts, secret)
+		if opts.Validate != nil {
+			if err := opts.Validate(answer); err != nil {
+				o.writePromptError(err.Error())
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// normalizeAnswer trims non-secret answers and substitutes the default for
+// an empty one.
+func normalizeAnswer(answer string, opts InputOptions, secret bool) string {
+	if !secret {
+		answer = strings.TrimSpace(answer)
+	}
+	if answer == "" {
+		return opts.Default
+	}
+	return answer
+}
+
+// readMasked reads a secret from the terminal f in raw mode. The caller must
+// hold o.promptMu.
+func (o *Output) readMasked(f *os.File) (string, error) {
+	restore, err := makeRaw(f.Fd())
+	if err != nil {
+		o.endPrompt()
+		return "", fmt.Errorf("cliout: cannot disable echo: %w", err)
+	}
+	defer restore()
+	defer restoreOnSignal(restore)()
+	return o.readMaskedFrom(f)
+}
+
+// readMaskedFrom reads keys from r until Enter, echoing "*" for each
+// character and erasing one for each Backspace. The line is always ended
+// before returning. The caller must hold o.promptMu.
+func (o *Output) readMaskedFrom(r io.Reader) (string, error) {
+	echo := func(s string) {
+		o.mu.Lock()
+		_, _ = io.WriteString(o.writerFor(LevelWarn), s)
+		o.mu.Unlock()
+	}
+	defer echo("\n")
+
+	var secret []rune
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, k := range parseKeys(buf[:n]) {
+			switch k.kind {
+			case keyEnter:
+				return string(secret), nil
+			case keyInterrupt:
+				return "", ErrInterrupted
+			case keyEOF:
+				if len(secret) == 0 {
+					return "", io.EOF
+				}
+			case keyBackspace:
+				if len(secret) > 0 {
+					secret = secret[:len(secret)-1]
+					echo("\b \b")
+				}
+			case keyRune:
+				secret = append(secret, k.r)
+				echo("*")
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
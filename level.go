@@ -1,5 +1,10 @@
 package cliout
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Level represents the verbosity level of output.
 type Level int
 
@@ -18,6 +23,9 @@ const (
 	LevelSilent
 )
 
+// numLevels is the number of printable levels (Trace through Error).
+const numLevels = LevelSilent
+
 // String returns a human-readable name for the level.
 func (l Level) String() string {
 	switch l {
@@ -37,3 +45,45 @@ func (l Level) String() string {
 		return "unknown"
 	}
 }
+
+// ParseLevel returns the level with the given name, as produced by
+// Level.String: "trace", "debug", "info", "warn", "error" or "silent".
+// The comparison is case-insensitive and ignores surrounding spaces.
+func ParseLevel(name string) (Level, error) {
+	lower := toLower(strings.TrimSpace(name))
+	for l := LevelTrace; l <= LevelSilent; l++ {
+		if l.String() == lower {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("cliout: unknown level %q (want trace, debug, info, warn, error or silent)", name)
+}
+
+// MarshalText implements encoding.TextMarshaler, producing the level's
+// name.
+func (l Level) MarshalText() ([]byte, error) {
+	if l < LevelTrace || l > LevelSilent {
+		return nil, fmt.Errorf("cliout: invalid level %d", int(l))
+	}
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting the names
+// accepted by ParseLevel.
+func (l *Level) UnmarshalText(text []byte) error {
+	parsed, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// Set implements flag.Value, so a Level can be used as a command-line
+// flag:
+//
+//	level := cliout.LevelInfo
+//	flag.Var(&level, "level", "output level")
+func (l *Level) Set(name string) error {
+	return l.UnmarshalText([]byte(name))
+}
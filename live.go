@@ -0,0 +1,241 @@
+package cliout
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// ANSI control sequences used to redraw live areas in place.
+const (
+	ansiClearLine = "\r\033[2K" // move to column 0 and erase the whole line
+	ansiLineUp    = "\033[1A"   // move the cursor up one line
+)
+
+// liveRow is one line of a live area, such as a spinner or progress bar.
+// Both methods are called with the owning Output's lock held.
+type liveRow interface {
+	// render returns the row as a single line without a trailing newline.
+	render() string
+	// tick advances any animation and reports whether a redraw is needed.
+	tick() bool
+}
+
+// liveArea is a block of rows at the bottom of the terminal that is redrawn
+// in place. At most one live area is attached to an Output at a time; while
+// it is attached, print erases it before writing a line and redraws it
+// afterwards so regular messages scroll above it.
+//
+// All fields are guarded by the owning Output's lock.
+type liveArea struct {
+	o        *Output
+	w        io.Writer
+	rows     []liveRow
+	drawn    int // number of lines currently on screen
+	holds    int // MultiProgress displays keeping the area attached while empty
+	stop     chan struct{}
+	detached bool
+}
+
+// liveAreaFor returns the live area that an animated row at the given level
+// should join, attaching a new one if needed, or nil if the row should not
+// be animated. Animation requires the level to be enabled and its
+// destination to be a terminal with color enabled, whose profile (ProfileNone
+// for TERM=dumb) shows that it understands escape sequences. The caller must
+// hold o.mu.
+func (o *Output) liveAreaFor(level Level) *liveArea {
+	if o.live != nil {
+		return o.live
+	}
+	if level < o.level || level < 0 || level >= numLevels || !o.tty[level] {
+		return nil
+	}
+	if !o.colorFor(level) || o.profile == ProfileNone {
+		return nil
+	}
+
+	a := &liveArea{
+		o:    o,
+		w:    o.writerFor(level),
+		stop: make(chan struct{}),
+	}
+	o.live = a
+	go a.run(spinnerInterval)
+	return a
+}
+
+// run advances row animations every interval until the area is detached.
+func (a *liveArea) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.o.mu.Lock()
+			if !a.detached {
+				redraw := false
+				for _, r := range a.rows {
+					if r.tick() {
+						redraw = true
+					}
+				}
+				if redraw {
+					a.refresh()
+				}
+			}
+			a.o.mu.Unlock()
+		}
+	}
+}
+
+// add appends a row and draws it.
+func (a *liveArea) add(r liveRow) {
+	a.rows = append(a.rows, r)
+	a.refresh()
+}
+
+// remove erases the area and drops r from it. The area is left undrawn so
+// the caller can print a result line before calling settle.
+func (a *liveArea) remove(r liveRow) {
+	a.clear()
+	for i, row := range a.rows {
+		if row == r {
+			a.rows = append(a.rows[:i], a.rows[i+1:]...)
+			break
+		}
+	}
+}
+
+// settle detaches the area once nothing needs it, or otherwise makes sure
+// it is drawn.
+func (a *liveArea) settle() {
+	if a.detached {
+		return
+	}
+	if len(a.rows) == 0 && a.holds == 0 {
+		a.detach()
+		return
+	}
+	if a.drawn == 0 {
+		a.redraw()
+	}
+}
+
+// detach erases the area and stops its animation.
+func (a *liveArea) detach() {
+	if a.detached {
+		return
+	}
+	a.clear()
+	a.detached = true
+	close(a.stop)
+	if a.o.live == a {
+		a.o.live = nil
+	}
+}
+
+// refresh redraws the area in place.
+func (a *liveArea) refresh() {
+	if a.detached {
+		return
+	}
+	a.clear()
+	a.redraw()
+}
+
+// clear erases the area from the terminal, leaving the cursor at the start
+// of the line where it began.
+func (a *liveArea) clear() {
+	if a.drawn == 0 {
+		return
+	}
+	_, _ = io.WriteString(a.w, eraseLines(a.drawn))
+	a.drawn = 0
+}
+
+// eraseLines returns the control sequence that erases the n lines ending at
+// the cursor's line, leaving the cursor at the start of the first of them.
+func eraseLines(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return ansiClearLine + strings.Repeat(ansiLineUp+ansiClearLine, n-1)
+}
+
+// redraw renders every row at the cursor position, unless a prompt is
+// active. The cursor is left at the end of the last row, without a trailing
+// newline. Rows are cut to the
+// terminal width: a row that wrapped would take more lines than clear
+// erases, leaving stale copies on screen.
+func (a *liveArea) redraw() {
+	if len(a.rows) == 0 || a.o.prompting {
+		return
+	}
+	width := a.o.widthFor(LevelInfo)
+	lines := make([]string, len(a.rows))
+	for i, r := range a.rows {
+		lines[i] = r.render()
+		if width > 0 {
+			lines[i] = truncate(lines[i], width)
+		}
+	}
+	_, _ = io.WriteString(a.w, strings.Join(lines, "\n"))
+	a.drawn = len(a.rows)
+}
+
+// task is the lifecycle shared by Spinner and ProgressBar: it is shown as a
+// row of a live area when animated, and finishes with a normal themed result
+// line. All fields are guarded by the owning Output's lock.
+type task struct {
+	o        *Output
+	row      liveRow
+	area     *liveArea      // nil when not animated
+	multi    *MultiProgress // nil for standalone tasks
+	finished bool
+}
+
+// begin shows the task: as a row of a live area on a terminal, or as a
+// single plain start line otherwise. The caller must hold t.o.mu.
+func (t *task) begin(msg string) {
+	if t.multi != nil {
+		t.area = t.multi.area
+		t.multi.pending++
+	} else {
+		t.area = t.o.liveAreaFor(LevelInfo)
+	}
+	if t.area == nil {
+		t.o.printLocked(LevelInfo, msg, false)
+		return
+	}
+	t.area.add(t.row)
+}
+
+// animated reports whether the task is currently drawn in a live area. The
+// caller must hold t.o.mu.
+func (t *task) animated() bool {
+	return t.area != nil && !t.area.detached && !t.finished
+}
+
+// end removes the task from its live area and, if print is true, writes the
+// final line exactly as the corresponding Output method would. Only the
+// first call has any effect. The caller must hold t.o.mu.
+func (t *task) end(level Level, msg string, isSuccess, print bool) {
+	if t.finished {
+		return
+	}
+	t.finished = true
+	if t.area != nil && !t.area.detached {
+		t.area.remove(t.row)
+	}
+	if print {
+		t.o.printLocked(level, msg, isSuccess)
+	}
+	if t.area != nil {
+		t.area.settle()
+	}
+	if t.multi != nil {
+		t.multi.done()
+	}
+}
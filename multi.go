@@ -0,0 +1,107 @@
+package cliout
+
+import "sync"
+
+// MultiProgress is a live display of several concurrent tasks, created by
+// Output.MultiProgress. Each spinner or progress bar started from it gets
+// its own row; lines printed on the Output from any goroutine scroll above
+// the rows without corrupting them, and each finished task collapses into a
+// normal themed result line.
+//
+// When the Info destination is not a terminal, or color is disabled, tasks
+// behave like standalone spinners and bars: one start line and one end line
+// each. A MultiProgress is safe for concurrent use.
+type MultiProgress struct {
+	o       *Output
+	area    *liveArea // nil when not animated
+	pending int       // tasks started but not yet finished
+	tasks   []*task
+	cond    *sync.Cond
+	stopped bool
+}
+
+// MultiProgress starts a live display for several concurrent tasks. Call
+// Wait or Stop when all tasks have been started to release the display.
+func (o *Output) MultiProgress() *MultiProgress {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	m := &MultiProgress{o: o, cond: sync.NewCond(&o.mu)}
+	m.area = o.liveAreaFor(LevelInfo)
+	if m.area != nil {
+		m.area.holds++
+	}
+	return m
+}
+
+// Spinner starts a spinner on its own row of the display.
+func (m *MultiProgress) Spinner(msg string) *Spinner {
+	m.o.mu.Lock()
+	defer m.o.mu.Unlock()
+	s := newSpinner(m.o, m.attached(), msg)
+	m.tasks = append(m.tasks, &s.task)
+	return s
+}
+
+// Progress starts a progress bar on its own row of the display.
+func (m *MultiProgress) Progress(msg string, total int64) *ProgressBar {
+	m.o.mu.Lock()
+	defer m.o.mu.Unlock()
+	p := newProgressBar(m.o, m.attached(), msg, total)
+	m.tasks = append(m.tasks, &p.task)
+	return p
+}
+
+// Wait blocks until every task started so far has finished, then releases
+// the display.
+func (m *MultiProgress) Wait() {
+	m.o.mu.Lock()
+	defer m.o.mu.Unlock()
+	for m.pending > 0 {
+		m.cond.Wait()
+	}
+	m.release()
+}
+
+// Stop erases any unfinished tasks without printing a result line for them
+// and releases the display immediately.
+func (m *MultiProgress) Stop() {
+	m.o.mu.Lock()
+	defer m.o.mu.Unlock()
+	for _, t := range m.tasks {
+		t.end(LevelInfo, "", false, false)
+	}
+	m.release()
+}
+
+// attached returns m if tasks should still be added to its display, or nil
+// once it has been released so that new tasks fall back to standalone
+// behaviour. The caller must hold m.o.mu.
+func (m *MultiProgress) attached() *MultiProgress {
+	if m.stopped {
+		return nil
+	}
+	return m
+}
+
+// done records that one of m's tasks has finished. The caller must hold
+// m.o.mu.
+func (m *MultiProgress) done() {
+	m.pending--
+	if m.pending == 0 {
+		m.cond.Broadcast()
+	}
+}
+
+// release drops m's hold on the live area, detaching it if no other tasks
+// are using it. The caller must hold m.o.mu.
+func (m *MultiProgress) release() {
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	if m.area != nil {
+		m.area.holds--
+		m.area.settle()
+	}
+}
@@ -1,38 +1,104 @@
 package cliout
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 const defaultPrefix = "»"
 
+// timeNow returns the current time. Tests replace it to control timestamps
+// and progress rate calculations.
+var timeNow = time.Now
+
+// MultilineStyle selects how the second and later lines of a message that
+// contains newlines are introduced.
+type MultilineStyle int
+
+const (
+	// MultilineAlign aligns later lines under the first line's text, leaving
+	// blank space where the prefix would be. This is the default, and is
+	// also how lines that are word-wrapped continue.
+	MultilineAlign MultilineStyle = iota
+	// MultilinePrefix repeats the prefix on every line.
+	MultilinePrefix
+)
+
 // Output holds all configuration for CLI output rendering.
+//
+// An Output is safe for concurrent use by multiple goroutines. Each message
+// is written to the underlying writer in a single call, so lines from
+// different goroutines never interleave.
 type Output struct {
+	*outputState
+	fields []Field // appended to every message; set by With
+	group  *group  // innermost group this Output prints in; set by Group
+}
+
+// outputState is the configuration and lock shared by an Output and any
+// children derived from it with With.
+type outputState struct {
+	mu           sync.Mutex // guards all fields below and serialises writes
 	writer       io.Writer
+	writers      [numLevels]io.Writer // per-level destinations; nil entries fall back to writer
+	plain        [numLevels]bool      // per-level: destination was detected as not a terminal
+	tty          [numLevels]bool      // per-level: destination is a terminal that supports redraws
+	live         *liveArea            // in-place animated area (spinners, progress bars), if any
+	prompting    bool                 // a prompt is using the terminal, so the live area is not drawn
 	level        Level
 	prefix       string
 	hasPrefix    bool
 	prefixColor  Color
 	messageColor Color
 	theme        Theme
+	pendingTheme string // CLI_THEME value naming no known theme; see adoptTheme
+	format       Format
+	groupStyle   GroupStyle
+	width        int // fixed line width; 0 detects it, negative disables wrapping
+	multiline    MultilineStyle
 	colorEnabled bool
-	noColorEnv   bool      // true when NO_COLOR was detected at construction time
-	exitFunc     func(int) // called by Fatal/Fatalf; defaults to os.Exit
+	profile      ColorProfile // colors the terminal can show; true colors are downsampled to it
+	noColorEnv   bool         // true when NO_COLOR was detected at construction time
+	exitFunc     func(int)    // called by Fatal/Fatalf; defaults to os.Exit
+	reader       io.Reader    // prompt input; nil means os.Stdin
+
+	promptMu   sync.Mutex    // held for the whole of a prompt, including reads; guards lineReader
+	lineReader *bufio.Reader // buffers reader between prompts
 }
 
 // New creates an Output with sensible defaults:
-//   - Writer: os.Stdout
+//   - Writer: os.Stdout for Trace, Debug and Info; os.Stderr for Warn and Error
 //   - Level: LevelInfo
 //   - Prefix: "»" (or the value of the CLI_PREFIX environment variable)
-//   - Theme: ThemeDefault (or the theme named by the CLI_THEME environment variable)
-//   - Color: auto-detected (disabled if NO_COLOR is set or stdout is not a TTY)
+//   - Theme: ThemeDefault (or the theme named by the CLI_THEME environment
+//     variable, which may also be the path of a theme file; see LoadTheme.
+//     A theme file that cannot be loaded is reported on the Warn
+//     destination)
+//   - Color: auto-detected per destination (disabled if NO_COLOR is set, and
+//     for any level whose destination is not a TTY)
+//   - Color profile: detected from COLORTERM, TERM and TERM_PROGRAM; true
+//     colors are downsampled on terminals that cannot show them
+//   - Format: FormatText (or FormatJSON if CLI_FORMAT is set to "json")
 func New() *Output {
 	theme := ThemeDefault
+	pendingTheme := ""
+	var themeErr error
 	if name, ok := os.LookupEnv("CLI_THEME"); ok && name != "" {
 		if t, found := ThemeByName(name); found {
 			theme = t
+		} else if isThemePath(name) {
+			if t, err := loadTheme(name); err == nil {
+				theme = t
+			} else {
+				themeErr = err
+			}
+		} else {
+			pendingTheme = name
 		}
 	}
 
@@ -45,15 +111,25 @@ func New() *Output {
 		}
 	}
 
-	o := &Output{
+	format := FormatText
+	if v, ok := os.LookupEnv("CLI_FORMAT"); ok {
+		if f, found := parseFormat(v); found {
+			format = f
+		}
+	}
+
+	o := &Output{outputState: &outputState{
 		writer:       os.Stdout,
 		level:        LevelInfo,
 		prefix:       prefix,
 		hasPrefix:    hasPrefix,
 		theme:        theme,
+		pendingTheme: pendingTheme,
+		format:       format,
 		colorEnabled: true,
+		profile:      detectProfile(),
 		exitFunc:     os.Exit,
-	}
+	}}
 
 	// Respect NO_COLOR environment variable.
 	// See https://no-color.org/
@@ -62,74 +138,268 @@ func New() *Output {
 		o.noColorEnv = true
 	}
 
-	// Disable color if stdout is not a terminal.
-	if f, ok := o.writer.(*os.File); ok {
-		if !isTerminal(f) {
-			o.colorEnabled = false
-		}
+	o.routeUnixStreams()
+
+	if themeErr != nil {
+		themeErrOnce.Do(func() {
+			fmt.Fprintf(o.writerFor(LevelWarn), "cliout: CLI_THEME: %v\n", themeErr)
+		})
 	}
 
 	return o
 }
 
-// isTerminal reports whether f is a terminal (character device).
+// themeErrOnce makes sure that a theme file named by CLI_THEME that cannot
+// be loaded is reported once, rather than by both the package-level output
+// and every Output created with New.
+var themeErrOnce sync.Once
+
+// isTerminal reports whether f is a terminal: a character device that,
+// where the platform allows checking, has terminal attributes (which rules
+// out devices such as /dev/null).
 func isTerminal(f *os.File) bool {
 	fi, err := f.Stat()
 	if err != nil {
 		return false
 	}
-	return (fi.Mode() & os.ModeCharDevice) != 0
+	return (fi.Mode()&os.ModeCharDevice) != 0 && hasTermios(f.Fd())
+}
+
+// routeUnixStreams sends Trace..Info to os.Stdout and Warn..Error to
+// os.Stderr, then re-runs color detection. The caller must hold o.mu or
+// have exclusive access to o.
+func (o *Output) routeUnixStreams() {
+	o.writer = os.Stdout
+	o.writers = [numLevels]io.Writer{}
+	for l := LevelWarn; l <= LevelError; l++ {
+		o.writers[l] = os.Stderr
+	}
+	o.detectColor()
+}
+
+// detectColor marks each level whose destination is a non-terminal file as
+// plain, so that piping stdout to a file does not strip color from
+// diagnostics still shown on a terminal via stderr (and vice versa). Writers
+// that are not *os.File are left alone. The caller must hold o.mu.
+func (o *Output) detectColor() {
+	for l := range o.plain {
+		o.plain[l] = isPlainWriter(o.writerFor(Level(l)))
+		o.tty[l] = isTerminalWriter(o.writerFor(Level(l)))
+	}
+}
+
+// isPlainWriter reports whether w is an *os.File that is not a terminal,
+// such as a regular file or a pipe, which should not receive color.
+func isPlainWriter(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && !isTerminal(f)
+}
+
+// isTerminalWriter reports whether w is an *os.File connected to a terminal.
+func isTerminalWriter(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// writerFor returns the destination for the given level. The caller must
+// hold o.mu.
+func (o *Output) writerFor(level Level) io.Writer {
+	if level >= 0 && level < numLevels && o.writers[level] != nil {
+		return o.writers[level]
+	}
+	return o.writer
+}
+
+// colorFor reports whether color should be emitted for the given level.
+// Color is never emitted in JSON mode. The caller must hold o.mu.
+func (o *Output) colorFor(level Level) bool {
+	if !o.colorEnabled || o.format == FormatJSON {
+		return false
+	}
+	if level >= 0 && level < numLevels {
+		return !o.plain[level]
+	}
+	return true
+}
+
+// paint renders text in c for o's color profile, or returns it unchanged
+// when colorEnabled is false. The caller must hold o.mu.
+func (o *Output) paint(c Color, text string, colorEnabled bool) string {
+	if !colorEnabled {
+		return text
+	}
+	return c.render(text, o.profile)
 }
 
 // --- Configuration methods ---
 
 // SetLevel sets the minimum output level. Messages below this level are suppressed.
 func (o *Output) SetLevel(l Level) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	o.level = l
 }
 
 // SetPrefix sets the prefix string prepended to each output line.
 func (o *Output) SetPrefix(p string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	o.prefix = p
 	o.hasPrefix = true
 }
 
 // ClearPrefix removes the prefix from output lines.
 func (o *Output) ClearPrefix() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	o.prefix = ""
 	o.hasPrefix = false
 }
 
 // SetPrefixColor sets the color of the prefix, overriding the theme's prefix color.
 func (o *Output) SetPrefixColor(c Color) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	o.prefixColor = c
 }
 
 // SetMessageColor sets the color for all messages, overriding per-level theme colors.
 func (o *Output) SetMessageColor(c Color) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	o.messageColor = c
 }
 
 // SetTheme sets the color theme. This affects prefix and per-level message colors
 // unless explicitly overridden with SetPrefixColor or SetMessageColor.
 func (o *Output) SetTheme(t Theme) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	o.theme = t
+	o.pendingTheme = ""
 }
 
-// SetWriter sets the output destination.
+// adoptTheme switches o to the theme named by CLI_THEME when New could not
+// find it, if a theme of that name has since been registered and SetTheme
+// has not been called in the meantime.
+func (o *Output) adoptTheme() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pendingTheme == "" {
+		return
+	}
+	if t, ok := ThemeByName(o.pendingTheme); ok {
+		o.theme = t
+		o.pendingTheme = ""
+	}
+}
+
+// SetWriter sets the output destination for all levels, replacing any
+// per-level routing set up by New, SetLevelWriter or SetWriterRange.
 func (o *Output) SetWriter(w io.Writer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	o.writer = w
+	o.writers = [numLevels]io.Writer{}
+	plain, tty := isPlainWriter(w), isTerminalWriter(w)
+	for l := range o.tty {
+		o.plain[l] = plain
+		o.tty[l] = tty
+	}
+}
+
+// SetLevelWriter sets the output destination for a single level. Fatal and
+// Fatalf are routed with LevelError.
+func (o *Output) SetLevelWriter(l Level, w io.Writer) {
+	o.SetWriterRange(l, l, w)
+}
+
+// SetWriterRange sets the output destination for every level from 'from' to
+// 'to' inclusive. For example, SetWriterRange(LevelWarn, LevelError, os.Stderr)
+// sends warnings and errors to stderr while leaving other levels untouched.
+// As in New, color is not written to files that are not terminals; call
+// SetColorEnabled afterwards to force it.
+func (o *Output) SetWriterRange(from, to Level, w io.Writer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if from < 0 {
+		from = 0
+	}
+	plain, tty := isPlainWriter(w), isTerminalWriter(w)
+	for l := from; l <= to && l < numLevels; l++ {
+		o.writers[l] = w
+		o.plain[l] = plain
+		o.tty[l] = tty
+	}
+}
+
+// UseUnixStreams applies the Unix-conventional routing that New uses by
+// default: Trace, Debug and Info go to os.Stdout, while Warn and Error go to
+// os.Stderr. Color is re-detected for each destination.
+func (o *Output) UseUnixStreams() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.routeUnixStreams()
+}
+
+// SetWidth sets the width, in columns, that messages are word-wrapped to
+// and tables are truncated to. The default of 0 uses the width of the
+// terminal each level is written to, or the COLUMNS environment variable
+// when the terminal cannot report it; levels written elsewhere are not
+// wrapped. A negative width disables wrapping and truncation.
+func (o *Output) SetWidth(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.width = n
+}
+
+// SetMultilineStyle sets how the second and later lines of a message that
+// contains newlines are introduced: aligned under the first line's text
+// (the default), or with the prefix repeated.
+func (o *Output) SetMultilineStyle(s MultilineStyle) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.multiline = s
 }
 
-// SetColorEnabled explicitly enables or disables color output.
+// SetFormat sets the output format. FormatJSON emits each message as a
+// single JSON object per line instead of colored, prefixed text.
+func (o *Output) SetFormat(f Format) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.format = f
+}
+
+// SetColorEnabled explicitly enables or disables color output for all levels,
+// overriding the per-destination TTY detection done by New.
 // If the NO_COLOR environment variable was set when this Output was created,
 // color remains disabled regardless of the value passed here.
 // See https://no-color.org/
 func (o *Output) SetColorEnabled(enabled bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	if o.noColorEnv {
 		return
 	}
 	o.colorEnabled = enabled
+	o.plain = [numLevels]bool{}
+}
+
+// SetColorProfile sets the color profile colors are rendered in,
+// overriding the one New detects from the environment. True colors are
+// converted to the nearest color the profile can show; ProfileNone
+// disables color.
+func (o *Output) SetColorProfile(p ColorProfile) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.profile = p
+}
+
+// ColorProfile returns the color profile colors are rendered in.
+func (o *Output) ColorProfile() ColorProfile {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.profile
 }
 
 // --- Output methods ---
@@ -209,49 +479,182 @@ func (o *Output) Successf(format string, a ...any) {
 // --- Inline color helpers ---
 
 // Colorize wraps text with the given color's ANSI escape codes. The result
-// respects this Output's color-enabled setting: when color is disabled the
-// text is returned unchanged. Use this to compose multi-color messages with
-// Infof, Errorf, etc.
+// respects this Output's color-enabled setting for the Info destination: when
+// color is disabled the text is returned unchanged. Use this to compose
+// multi-color messages with Infof, Errorf, etc.
 func (o *Output) Colorize(text string, c Color) string {
-	return c.apply(text, o.colorEnabled)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.paint(c, text, o.colorFor(LevelInfo))
+}
+
+// Badge renders label as a pill: bold text padded with a space on each
+// side, on a background of bg's foreground color, such as " PASS " on
+// green. The text is black or white, whichever is easier to read on bg. If
+// bg is ColorDefault the terminal's own colors are swapped instead. When
+// color is disabled for the Info destination the label is shown in
+// brackets, as "[PASS]", which takes the same width.
+func (o *Output) Badge(label string, bg Color) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.colorFor(LevelInfo) || o.profile == ProfileNone {
+		return "[" + label + "]"
+	}
+	style := ColorDefault.Reverse().Bold()
+	if !bg.tone.isDefault() {
+		style = readableOn(bg.tone).On(bg).Bold()
+	}
+	return o.paint(style, " "+label+" ", true)
 }
 
 // --- Internal rendering ---
 
 // print is the core rendering method. It handles level filtering, color application,
-// prefix rendering, and writing the final output line.
+// prefix rendering, and writing the final output line. The lock is held for
+// the whole call so configuration cannot change mid-render and the line is
+// written to the writer atomically.
 func (o *Output) print(level Level, msg string, isSuccess bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.printLocked(level, msg, isSuccess)
+}
+
+// printLocked is print for callers that already hold o.mu.
+func (o *Output) printLocked(level Level, msg string, isSuccess bool) {
 	if level < o.level {
 		return
 	}
+	if o.format == FormatJSON {
+		o.writeLine(level, jsonLine(level, msg, isSuccess, o.fields))
+		return
+	}
 
-	// Determine message color: explicit override > success color > theme per-level color.
-	var msgColor Color
-	if !o.messageColor.isDefault() {
-		msgColor = o.messageColor
-	} else if isSuccess {
-		msgColor = o.theme.SuccessColor
-	} else {
-		msgColor = o.colorForLevel(level)
+	msgColor := o.messageColorFor(level, isSuccess)
+
+	// Build the output line.
+	// Each line of a multi-line message is colored and laid out on its own,
+	// and fields follow the last line.
+	colorEnabled := o.colorFor(level)
+	lines := splitLines(msg)
+	var b strings.Builder
+	for i, line := range lines {
+		content := o.paint(msgColor, line, colorEnabled)
+		if i == len(lines)-1 {
+			content += o.renderFields(o.fields, colorEnabled)
+		}
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		prefixed := i == 0 || o.multiline == MultilinePrefix
+		b.WriteString(o.layoutLocked(level, content, prefixed, colorEnabled))
 	}
 
-	// Determine prefix color: explicit override > theme prefix color.
-	prefixColor := o.theme.PrefixColor
-	if !o.prefixColor.isDefault() {
-		prefixColor = o.prefixColor
+	o.writeLine(level, b.String())
+}
+
+// layoutLocked word-wraps one line of content to the level's width, with
+// continuation lines aligned under the start of the message. The first
+// line starts with the prefix if prefixed is set, and is otherwise aligned
+// like a continuation line. The caller must hold o.mu.
+func (o *Output) layoutLocked(level Level, content string, prefixed, colorEnabled bool) string {
+	prefix := o.renderPrefix(colorEnabled)
+	cont := o.renderContinuation(colorEnabled)
+	lead := cont
+	if prefixed {
+		lead = prefix
+	}
+	if content == "" {
+		return strings.TrimRight(lead, " ")
 	}
+	avail := o.widthFor(level) - visibleWidth(prefix)
+	if avail < minWrapWidth {
+		return lead + content
+	}
+	return lead + strings.Join(wrap(content, avail), "\n"+cont)
+}
 
-	// Build the output line.
-	var line string
+// printRenderedLocked writes content, which has already been colored by the
+// caller, at the given level with the usual prefix. It is used by renderers
+// such as Table that color parts of a line individually. In JSON mode it
+// is emitted as a message with o's fields. The caller must hold o.mu.
+func (o *Output) printRenderedLocked(level Level, content string) {
+	if level < o.level {
+		return
+	}
+	if o.format == FormatJSON {
+		o.writeLine(level, jsonLine(level, content, false, o.fields))
+		return
+	}
+	o.writeLine(level, o.renderPrefix(o.colorFor(level))+content)
+}
+
+// renderPrefix returns the colored prefix followed by a space, or an empty
+// string if no prefix is set, followed by any group indentation. The caller
+// must hold o.mu.
+func (o *Output) renderPrefix(colorEnabled bool) string {
+	if !o.hasPrefix || o.prefix == "" {
+		return o.renderIndent(colorEnabled)
+	}
+	return o.paint(o.currentPrefixColor(), o.prefix, colorEnabled) + " " + o.renderIndent(colorEnabled)
+}
+
+// renderContinuation returns the lead-in for continuation lines: blank space
+// as wide as the prefix, followed by any group indentation. The caller must
+// hold o.mu.
+func (o *Output) renderContinuation(colorEnabled bool) string {
+	lead := ""
 	if o.hasPrefix && o.prefix != "" {
-		coloredPrefix := prefixColor.apply(o.prefix, o.colorEnabled)
-		coloredMsg := msgColor.apply(msg, o.colorEnabled)
-		line = coloredPrefix + " " + coloredMsg
-	} else {
-		line = msgColor.apply(msg, o.colorEnabled)
+		lead = strings.Repeat(" ", visibleWidth(o.prefix)+1)
 	}
+	return lead + o.renderIndent(colorEnabled)
+}
 
-	_, _ = fmt.Fprintln(o.writer, line)
+// widthFor returns the width that lines at the given level are wrapped and
+// truncated to, or 0 for no limit. The caller must hold o.mu.
+func (o *Output) widthFor(level Level) int {
+	switch {
+	case o.width > 0:
+		return o.width
+	case o.width < 0:
+		return 0
+	}
+	return writerWidth(o.writerFor(level))
+}
+
+// writeLine writes a rendered line to the level's destination. If a live
+// region is active it is erased first and redrawn afterwards, so the line
+// scrolls above the animation instead of being overwritten by it. The caller
+// must hold o.mu.
+func (o *Output) writeLine(level Level, line string) {
+	w := o.writerFor(level)
+	if o.live == nil {
+		_, _ = io.WriteString(w, line+"\n")
+		return
+	}
+	o.live.clear()
+	_, _ = io.WriteString(w, line+"\n")
+	o.live.redraw()
+}
+
+// messageColorFor returns the message color for a level.
+// Priority: explicit override > success color > theme per-level color.
+func (o *Output) messageColorFor(level Level, isSuccess bool) Color {
+	if !o.messageColor.isDefault() {
+		return o.messageColor
+	}
+	if isSuccess {
+		return o.theme.SuccessColor
+	}
+	return o.colorForLevel(level)
+}
+
+// currentPrefixColor returns the prefix color.
+// Priority: explicit override > theme prefix color.
+func (o *Output) currentPrefixColor() Color {
+	if !o.prefixColor.isDefault() {
+		return o.prefixColor
+	}
+	return o.theme.PrefixColor
 }
 
 // colorForLevel returns the theme color for a given output level.
@@ -0,0 +1,320 @@
+package cliout
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// String returns c in the text form accepted by UnmarshalText, such as
+// "red", "#FF5733", "256:202" or "bold white on #C0392B". ColorDefault is
+// "default".
+func (c Color) String() string {
+	var words []string
+	for _, ac := range attrCodes {
+		if c.attrs&ac.a != 0 {
+			words = append(words, attrName(ac.a))
+		}
+	}
+	if !c.tone.isDefault() || (len(words) == 0 && c.bg.isDefault()) {
+		words = append(words, c.tone.String())
+	}
+	if !c.bg.isDefault() {
+		words = append(words, "on", c.bg.String())
+	}
+	return strings.Join(words, " ")
+}
+
+// MarshalText implements encoding.TextMarshaler, producing the form
+// returned by String.
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting every form
+// accepted by ParseColor, including those produced by String.
+func (c *Color) UnmarshalText(text []byte) error {
+	parsed, err := parseColor(string(text))
+	if err != nil {
+		return fmt.Errorf("cliout: %w", err)
+	}
+	*c = parsed
+	return nil
+}
+
+// String returns t as a single color word.
+func (t tone) String() string {
+	switch {
+	case t.isDefault():
+		return "default"
+	case t.isTrueColor:
+		return fmt.Sprintf("#%02X%02X%02X", t.r, t.g, t.b)
+	case t.isIndexed:
+		return fmt.Sprintf("256:%d", t.index)
+	}
+	for name, code := range ansiNames {
+		if code == t.ansiCode {
+			return name
+		}
+	}
+	return "default"
+}
+
+// attrName returns the name of the single attribute a.
+func attrName(a attr) string {
+	for name, v := range attrNames {
+		if v == a {
+			return name
+		}
+	}
+	return ""
+}
+
+// ansiNames maps the names accepted for ANSI colors to their foreground
+// codes.
+var ansiNames = map[string]int{
+	"black":          30,
+	"red":            31,
+	"green":          32,
+	"yellow":         33,
+	"blue":           34,
+	"magenta":        35,
+	"cyan":           36,
+	"white":          37,
+	"bright-black":   90,
+	"bright-red":     91,
+	"bright-green":   92,
+	"bright-yellow":  93,
+	"bright-blue":    94,
+	"bright-magenta": 95,
+	"bright-cyan":    96,
+	"bright-white":   97,
+}
+
+// attrNames maps the names accepted for text attributes to the attributes.
+var attrNames = map[string]attr{
+	"bold":          attrBold,
+	"dim":           attrDim,
+	"italic":        attrItalic,
+	"underline":     attrUnderline,
+	"reverse":       attrReverse,
+	"strikethrough": attrStrikethrough,
+}
+
+// ParseColor parses a color. It accepts:
+//
+//	#RGB, #RRGGBB          hex true colors, as in "#F80" or "#FF8800"
+//	rgb(R, G, B)           components from 0 to 255, or percentages
+//	hsl(H, S%, L%)         hue in degrees, saturation and lightness
+//	red, bright-blue, ...  the ANSI colors of the Color* variables
+//	coral, rebeccapurple   the CSS named colors
+//	256:N                  entry N of the 256-color palette
+//	default                ColorDefault
+//
+// Names are case-insensitive. ANSI names may also be written without the
+// hyphen or in the style of the variables, as in "BrightBlue". The eight
+// CSS names that are also ANSI names, such as "red", select the ANSI
+// color; write the CSS value in hex to get it instead.
+//
+// The color may be preceded by text attributes ("bold", "dim", "italic",
+// "underline", "strikethrough", "reverse") and followed by "on" and a
+// background color, all separated by spaces, as in "bold white on
+// #C0392B". This is the form produced by Color.String. An empty string is
+// ColorDefault.
+//
+// Unlike Hex, ParseColor reports why a string is not a valid color.
+func ParseColor(s string) (Color, error) {
+	c, err := parseColor(s)
+	if err != nil {
+		return ColorDefault, fmt.Errorf("cliout: %w", err)
+	}
+	return c, nil
+}
+
+// parseColor implements ParseColor, returning errors without the package
+// prefix so that callers can add context.
+func parseColor(s string) (Color, error) {
+	var c Color
+	words, err := splitWords(s)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color %q: %w", s, err)
+	}
+	haveFg := false
+	for i := 0; i < len(words); i++ {
+		word := strings.ToLower(words[i])
+		if a, ok := attrNames[word]; ok {
+			c.attrs |= a
+			continue
+		}
+		if word == "on" {
+			if i+1 >= len(words) {
+				return Color{}, fmt.Errorf("invalid color %q: \"on\" must be followed by a background color", s)
+			}
+			i++
+			bg, err := parseTone(words[i])
+			if err != nil {
+				return Color{}, fmt.Errorf("invalid color %q: %w", s, err)
+			}
+			c.bg = bg
+			continue
+		}
+		if haveFg {
+			return Color{}, fmt.Errorf("invalid color %q: more than one foreground color", s)
+		}
+		fg, err := parseTone(words[i])
+		if err != nil {
+			return Color{}, fmt.Errorf("invalid color %q: %w", s, err)
+		}
+		c.tone = fg
+		haveFg = true
+	}
+	return c, nil
+}
+
+// splitWords splits s at spaces, keeping the arguments of rgb() and hsl()
+// together with the function name even if they contain spaces.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth == 0 {
+				return nil, errors.New("unexpected \")\"")
+			}
+			depth--
+		case depth == 0 && unicode.IsSpace(r):
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteRune(r)
+	}
+	if depth > 0 {
+		return nil, errors.New("missing \")\"")
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// parseTone parses a single color word.
+func parseTone(word string) (tone, error) {
+	lower := strings.ToLower(word)
+	switch {
+	case lower == "default":
+		return tone{}, nil
+	case strings.HasPrefix(lower, "#"):
+		return parseHex(word)
+	case strings.HasPrefix(lower, "256:"):
+		n, err := strconv.Atoi(word[len("256:"):])
+		if err != nil || n < 0 || n > 255 {
+			return tone{}, fmt.Errorf("256-color index %q is not a number from 0 to 255", word[len("256:"):])
+		}
+		return Color256(uint8(n)).tone, nil
+	case strings.HasPrefix(lower, "rgb(") && strings.HasSuffix(lower, ")"):
+		return parseRGBFunc(word)
+	case strings.HasPrefix(lower, "hsl(") && strings.HasSuffix(lower, ")"):
+		return parseHSLFunc(word)
+	}
+	folded := strings.NewReplacer("-", "", "_", "").Replace(lower)
+	for name, code := range ansiNames {
+		if strings.ReplaceAll(name, "-", "") == folded {
+			return ansiColor(code).tone, nil
+		}
+	}
+	if v, ok := cssColors[lower]; ok {
+		return RGB(uint8(v>>16), uint8(v>>8), uint8(v)).tone, nil
+	}
+	return tone{}, fmt.Errorf("unknown color name %q", word)
+}
+
+// parseHex parses a "#RGB" or "#RRGGBB" color.
+func parseHex(word string) (tone, error) {
+	digits := word[1:]
+	if len(digits) != 3 && len(digits) != 6 {
+		return tone{}, fmt.Errorf("hex colors need 3 or 6 digits after #, %q has %d", word, len(digits))
+	}
+	if i := strings.IndexFunc(digits, func(r rune) bool { return !strings.ContainsRune("0123456789abcdefABCDEF", r) }); i >= 0 {
+		r, _ := utf8.DecodeRuneInString(digits[i:])
+		return tone{}, fmt.Errorf("%q in %q is not a hex digit", r, word)
+	}
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	v, _ := strconv.ParseUint(digits, 16, 32)
+	return RGB(uint8(v>>16), uint8(v>>8), uint8(v)).tone, nil
+}
+
+// funcArgs splits the three arguments of a call such as "rgb(1, 2, 3)",
+// which may be separated by commas or spaces.
+func funcArgs(word string) ([]string, error) {
+	open := strings.IndexByte(word, '(')
+	name := strings.ToLower(word[:open])
+	args := strings.FieldsFunc(word[open+1:len(word)-1], func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	if len(args) != 3 {
+		return nil, fmt.Errorf("%s() needs 3 arguments, %q has %d", name, word, len(args))
+	}
+	return args, nil
+}
+
+// parseRGBFunc parses "rgb(R, G, B)", where each component is a number
+// from 0 to 255 or a percentage.
+func parseRGBFunc(word string) (tone, error) {
+	args, err := funcArgs(word)
+	if err != nil {
+		return tone{}, err
+	}
+	var rgb [3]uint8
+	for i, arg := range args {
+		if pct, ok := strings.CutSuffix(arg, "%"); ok {
+			v, err := strconv.ParseFloat(pct, 64)
+			if err != nil || v < 0 || v > 100 {
+				return tone{}, fmt.Errorf("rgb() component %q is not a percentage from 0%% to 100%%", arg)
+			}
+			rgb[i] = uint8(math.Round(v * 255 / 100))
+			continue
+		}
+		v, err := strconv.Atoi(arg)
+		if err != nil || v < 0 || v > 255 {
+			return tone{}, fmt.Errorf("rgb() component %q is not a number from 0 to 255", arg)
+		}
+		rgb[i] = uint8(v)
+	}
+	return RGB(rgb[0], rgb[1], rgb[2]).tone, nil
+}
+
+// parseHSLFunc parses "hsl(H, S%, L%)", where the hue is in degrees and
+// may end in "deg".
+func parseHSLFunc(word string) (tone, error) {
+	args, err := funcArgs(word)
+	if err != nil {
+		return tone{}, err
+	}
+	h, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(args[0]), "deg"), 64)
+	if err != nil {
+		return tone{}, fmt.Errorf("hsl() hue %q is not a number of degrees", args[0])
+	}
+	var sl [2]float64
+	for i, arg := range args[1:] {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+		if err != nil || v < 0 || v > 100 {
+			return tone{}, fmt.Errorf("hsl() %s %q is not a percentage from 0%% to 100%%", [2]string{"saturation", "lightness"}[i], arg)
+		}
+		sl[i] = v / 100
+	}
+	r, g, b := hslToRGB(h, sl[0], sl[1])
+	return RGB(r, g, b).tone, nil
+}
@@ -0,0 +1,170 @@
+package cliout
+
+import (
+	"os"
+	"strings"
+)
+
+// ColorProfile describes how many colors a terminal can display. Colors
+// that the profile cannot show are rendered as the nearest color it can.
+type ColorProfile int
+
+const (
+	// ProfileNone emits no color at all.
+	ProfileNone ColorProfile = iota
+	// ProfileANSI supports the 16 standard and bright ANSI colors.
+	ProfileANSI
+	// ProfileANSI256 supports the xterm 256-color palette.
+	ProfileANSI256
+	// ProfileTrueColor supports 24-bit RGB color.
+	ProfileTrueColor
+)
+
+// String returns the profile's name: "none", "ansi", "ansi256" or
+// "truecolor".
+func (p ColorProfile) String() string {
+	switch p {
+	case ProfileNone:
+		return "none"
+	case ProfileANSI:
+		return "ansi"
+	case ProfileANSI256:
+		return "ansi256"
+	case ProfileTrueColor:
+		return "truecolor"
+	default:
+		return "unknown"
+	}
+}
+
+// detectProfile works out the color profile of the terminal from the
+// COLORTERM, TERM and TERM_PROGRAM environment variables, and WT_SESSION
+// (set by Windows Terminal). Terminals that say nothing about themselves
+// are assumed to support the 16 ANSI colors.
+func detectProfile() ColorProfile {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ProfileTrueColor
+	}
+
+	term := strings.ToLower(os.Getenv("TERM"))
+	if term == "dumb" {
+		return ProfileNone
+	}
+	if strings.Contains(term, "truecolor") || strings.Contains(term, "24bit") || strings.Contains(term, "direct") {
+		return ProfileTrueColor
+	}
+
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "Hyper":
+		return ProfileTrueColor
+	case "Apple_Terminal":
+		return ProfileANSI256
+	}
+	if os.Getenv("WT_SESSION") != "" {
+		return ProfileTrueColor
+	}
+
+	if strings.Contains(term, "256color") {
+		return ProfileANSI256
+	}
+	return ProfileANSI
+}
+
+// ansiPalette holds the RGB values xterm uses for the 16 ANSI colors, in
+// order: black, red, green, yellow, blue, magenta, cyan and white, then
+// their bright variants.
+var ansiPalette = [16][3]uint8{
+	{0, 0, 0},
+	{205, 0, 0},
+	{0, 205, 0},
+	{205, 205, 0},
+	{0, 0, 238},
+	{205, 0, 205},
+	{0, 205, 205},
+	{229, 229, 229},
+	{127, 127, 127},
+	{255, 0, 0},
+	{0, 255, 0},
+	{255, 255, 0},
+	{92, 92, 255},
+	{255, 0, 255},
+	{0, 255, 255},
+	{255, 255, 255},
+}
+
+// cubeLevels are the component values of the 6x6x6 color cube that makes
+// up entries 16-231 of the xterm 256-color palette.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// rgbTo16 returns the SGR foreground code (30-37 or 90-97) of the ANSI
+// color nearest to r, g, b.
+func rgbTo16(r, g, b uint8) int {
+	best, bestDist := 0, -1
+	for i, c := range ansiPalette {
+		if d := colorDist(r, g, b, c[0], c[1], c[2]); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return ansiCode(best)
+}
+
+// ansiCode returns the SGR foreground code of ANSI color i (0-15).
+func ansiCode(i int) int {
+	if i < 8 {
+		return 30 + i
+	}
+	return 90 + i - 8
+}
+
+// paletteRGB returns the RGB value of entry n of the xterm 256-color
+// palette.
+func paletteRGB(n uint8) (r, g, b uint8) {
+	switch {
+	case n < 16:
+		c := ansiPalette[n]
+		return c[0], c[1], c[2]
+	case n < 232:
+		i := n - 16
+		return cubeLevels[i/36], cubeLevels[i/6%6], cubeLevels[i%6]
+	default:
+		v := 8 + 10*(n-232)
+		return v, v, v
+	}
+}
+
+// rgbTo256 returns the index of the xterm 256-color palette entry nearest
+// to r, g, b, choosing between the color cube (16-231) and the grayscale
+// ramp (232-255). The first 16 entries are skipped because terminals let
+// users redefine them.
+func rgbTo256(r, g, b uint8) uint8 {
+	qr, qg, qb := cubeIndex(r), cubeIndex(g), cubeIndex(b)
+	cube := 16 + 36*qr + 6*qg + qb
+	cubeDist := colorDist(r, g, b, cubeLevels[qr], cubeLevels[qg], cubeLevels[qb])
+
+	avg := (int(r) + int(g) + int(b)) / 3
+	gi := min(max((avg-3)/10, 0), 23)
+	gv := uint8(8 + 10*gi)
+	if colorDist(r, g, b, gv, gv, gv) < cubeDist {
+		return uint8(232 + gi)
+	}
+	return uint8(cube)
+}
+
+// cubeIndex returns the index into cubeLevels nearest to v.
+func cubeIndex(v uint8) int {
+	switch {
+	case v < 48:
+		return 0
+	case v < 115:
+		return 1
+	default:
+		return (int(v) - 35) / 40
+	}
+}
+
+// colorDist returns the squared distance between two RGB colors.
+func colorDist(r1, g1, b1, r2, g2, b2 uint8) int {
+	dr, dg, db := int(r1)-int(r2), int(g1)-int(g2), int(b1)-int(b2)
+	return dr*dr + dg*dg + db*db
+}
@@ -0,0 +1,321 @@
+package cliout
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// progressBarWidth is the number of cells in a drawn progress bar.
+const progressBarWidth = 30
+
+// progressRedrawInterval limits how often a bar is redrawn on a terminal.
+var progressRedrawInterval = 50 * time.Millisecond
+
+// progressTextInterval limits how often a textual update is printed when the
+// destination is not a terminal.
+var progressTextInterval = 2 * time.Second
+
+// ProgressBar tracks progress towards a total, created by Output.Progress or
+// MultiProgress.Progress.
+//
+// On a terminal the bar is redrawn in place below regular output, themed
+// with the active Theme: the filled portion uses PrefixColor and the
+// statistics use DebugColor. When the Info destination is not a terminal, or
+// color is disabled, the bar instead prints a throttled textual update at
+// most every couple of seconds.
+//
+// Finish a bar with exactly one of Success, Warn, Error or Stop. A
+// ProgressBar is safe for concurrent use.
+type ProgressBar struct {
+	task
+	msg      string
+	total    int64
+	current  int64
+	bytes    bool
+	start    time.Time
+	lastDraw time.Time
+}
+
+// Progress starts a progress bar showing msg at info level, tracking
+// progress towards total. A total of zero or less means the total is
+// unknown: the bar then shows only the count and rate.
+func (o *Output) Progress(msg string, total int64) *ProgressBar {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return newProgressBar(o, nil, msg, total)
+}
+
+// newProgressBar creates and shows a progress bar. The caller must hold o.mu.
+func newProgressBar(o *Output, m *MultiProgress, msg string, total int64) *ProgressBar {
+	p := &ProgressBar{msg: msg, total: total, start: timeNow()}
+	p.lastDraw = p.start
+	p.task = task{o: o, row: p, multi: m}
+	p.begin(msg)
+	return p
+}
+
+// Add advances the bar by n.
+func (p *ProgressBar) Add(n int64) {
+	p.o.mu.Lock()
+	defer p.o.mu.Unlock()
+	p.current += n
+	p.refresh()
+}
+
+// Set sets the bar's current value.
+func (p *ProgressBar) Set(current int64) {
+	p.o.mu.Lock()
+	defer p.o.mu.Unlock()
+	p.current = current
+	p.refresh()
+}
+
+// SetTotal changes the bar's total, for example once a Content-Length
+// header has been read.
+func (p *ProgressBar) SetTotal(total int64) {
+	p.o.mu.Lock()
+	defer p.o.mu.Unlock()
+	p.total = total
+	p.refresh()
+}
+
+// Update replaces the bar's message.
+func (p *ProgressBar) Update(msg string) {
+	p.o.mu.Lock()
+	defer p.o.mu.Unlock()
+	p.msg = msg
+	p.refresh()
+}
+
+// Updatef replaces the bar's message with a formatted message.
+func (p *ProgressBar) Updatef(format string, a ...any) {
+	p.Update(fmt.Sprintf(format, a...))
+}
+
+// Reader wraps r so that every byte read from it advances the bar. The bar
+// then formats its counts and rate as byte sizes.
+func (p *ProgressBar) Reader(r io.Reader) io.Reader {
+	p.o.mu.Lock()
+	defer p.o.mu.Unlock()
+	p.bytes = true
+	return &progressReader{r: r, p: p}
+}
+
+// Writer wraps w so that every byte written to it advances the bar. The bar
+// then formats its counts and rate as byte sizes.
+func (p *ProgressBar) Writer(w io.Writer) io.Writer {
+	p.o.mu.Lock()
+	defer p.o.mu.Unlock()
+	p.bytes = true
+	return &progressWriter{w: w, p: p}
+}
+
+// Success finishes the bar and prints msg as a success message.
+func (p *ProgressBar) Success(msg string) {
+	p.finish(LevelInfo, msg, true)
+}
+
+// Successf finishes the bar and prints a formatted message as a success message.
+func (p *ProgressBar) Successf(format string, a ...any) {
+	p.finish(LevelInfo, fmt.Sprintf(format, a...), true)
+}
+
+// Warn finishes the bar and prints msg as a warn-level message.
+func (p *ProgressBar) Warn(msg string) {
+	p.finish(LevelWarn, msg, false)
+}
+
+// Warnf finishes the bar and prints a formatted message as a warn-level message.
+func (p *ProgressBar) Warnf(format string, a ...any) {
+	p.finish(LevelWarn, fmt.Sprintf(format, a...), false)
+}
+
+// Error finishes the bar and prints msg as an error-level message.
+func (p *ProgressBar) Error(msg string) {
+	p.finish(LevelError, msg, false)
+}
+
+// Errorf finishes the bar and prints a formatted message as an error-level message.
+func (p *ProgressBar) Errorf(format string, a ...any) {
+	p.finish(LevelError, fmt.Sprintf(format, a...), false)
+}
+
+// Stop finishes the bar and erases it without printing a final line.
+func (p *ProgressBar) Stop() {
+	p.o.mu.Lock()
+	defer p.o.mu.Unlock()
+	p.end(LevelInfo, "", false, false)
+}
+
+// finish erases the bar and prints the final line.
+func (p *ProgressBar) finish(level Level, msg string, isSuccess bool) {
+	p.o.mu.Lock()
+	defer p.o.mu.Unlock()
+	p.end(level, msg, isSuccess, true)
+}
+
+// refresh redraws the bar, or prints a textual update, if enough time has
+// passed since the last one. The caller must hold p.o.mu.
+func (p *ProgressBar) refresh() {
+	if p.finished {
+		return
+	}
+	now := timeNow()
+	if p.area != nil {
+		complete := p.total > 0 && p.current >= p.total
+		if now.Sub(p.lastDraw) < progressRedrawInterval && !complete {
+			return
+		}
+		p.lastDraw = now
+		if p.animated() {
+			p.area.refresh()
+		}
+		return
+	}
+	if now.Sub(p.lastDraw) < progressTextInterval {
+		return
+	}
+	p.lastDraw = now
+	p.o.printLocked(LevelInfo, p.msg+" "+p.textStats(now), false)
+}
+
+// tick implements liveRow. Bars are redrawn as they advance rather than on
+// a timer.
+func (p *ProgressBar) tick() bool {
+	return false
+}
+
+// render implements liveRow.
+func (p *ProgressBar) render() string {
+	o := p.o
+	now := timeNow()
+	var b strings.Builder
+	b.WriteString(o.renderPrefix(true))
+	b.WriteString(o.paint(o.messageColorFor(LevelInfo, false), p.msg, true))
+	b.WriteByte(' ')
+
+	if p.total > 0 {
+		filled := int(float64(progressBarWidth) * p.fraction())
+		b.WriteString(o.paint(o.currentPrefixColor(), strings.Repeat("█", filled), true))
+		b.WriteString(o.paint(o.theme.DebugColor, strings.Repeat("░", progressBarWidth-filled), true))
+		b.WriteByte(' ')
+		b.WriteString(fmt.Sprintf("%3d%%", int(p.fraction()*100)))
+		b.WriteByte(' ')
+	}
+	b.WriteString(o.paint(o.theme.DebugColor, strings.Join(p.stats(now), "  "), true))
+	return b.String()
+}
+
+// textStats returns the statistics used for non-terminal updates, e.g.
+// "45% (45/100, 12/s, ETA 5s)".
+func (p *ProgressBar) textStats(now time.Time) string {
+	stats := "(" + strings.Join(p.stats(now), ", ") + ")"
+	if p.total > 0 {
+		return fmt.Sprintf("%d%% %s", int(p.fraction()*100), stats)
+	}
+	return stats
+}
+
+// stats returns the count, rate and (when the total is known) ETA.
+func (p *ProgressBar) stats(now time.Time) []string {
+	count := p.format(p.current)
+	if p.total > 0 {
+		count += "/" + p.format(p.total)
+	}
+	stats := []string{count}
+
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed <= 0 || p.current <= 0 {
+		return stats
+	}
+	rate := float64(p.current) / elapsed
+	stats = append(stats, p.format(int64(rate))+"/s")
+	if p.total > 0 && p.current < p.total {
+		eta := time.Duration(float64(p.total-p.current) / rate * float64(time.Second))
+		stats = append(stats, "ETA "+formatETA(eta))
+	}
+	return stats
+}
+
+// fraction returns the completed fraction, clamped to [0, 1].
+func (p *ProgressBar) fraction() float64 {
+	if p.total <= 0 {
+		return 0
+	}
+	f := float64(p.current) / float64(p.total)
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// format formats n as a byte size or a plain count.
+func (p *ProgressBar) format(n int64) string {
+	if p.bytes {
+		return formatBytes(n)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// formatBytes formats n using decimal (SI) units, e.g. "4.5 MB".
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// formatETA formats d compactly, e.g. "45s", "2m05s" or "1h02m".
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	s := int(d % time.Minute / time.Second)
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, s)
+	default:
+		return fmt.Sprintf("%ds", s)
+	}
+}
+
+// progressReader advances a ProgressBar as bytes are read.
+type progressReader struct {
+	r io.Reader
+	p *ProgressBar
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if n > 0 {
+		r.p.Add(int64(n))
+	}
+	return n, err
+}
+
+// progressWriter advances a ProgressBar as bytes are written.
+type progressWriter struct {
+	w io.Writer
+	p *ProgressBar
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	if n > 0 {
+		w.p.Add(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,178 @@
+package cliout
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotInteractive is returned by prompts when the input is not a
+// terminal, so that scripts and CI jobs fail fast instead of hanging while
+// waiting for an answer that will never come.
+var ErrNotInteractive = errors.New("cliout: input is not a terminal")
+
+// ErrInterrupted is returned by prompts that read keys in raw terminal mode
+// when the user presses Ctrl-C, which then no longer raises SIGINT.
+var ErrInterrupted = errors.New("cliout: prompt interrupted")
+
+// SetReader sets the input that prompts read answers from. The default is
+// os.Stdin. When the reader is an *os.File that is not a terminal, prompts
+// return ErrNotInteractive without reading; any other reader is read line
+// by line, which makes prompts easy to drive from tests.
+func (o *Output) SetReader(r io.Reader) {
+	o.promptMu.Lock()
+	defer o.promptMu.Unlock()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.reader = r
+	o.lineReader = nil
+}
+
+// input returns the reader prompts read from. The caller must hold
+// o.promptMu.
+func (o *Output) input() io.Reader {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.reader == nil {
+		return os.Stdin
+	}
+	return o.reader
+}
+
+// interactive reports whether prompts may read from the input: it must not
+// be a file that is not a terminal. The caller must hold o.promptMu.
+func (o *Output) interactive() bool {
+	if f, ok := o.input().(*os.File); ok {
+		return isTerminal(f)
+	}
+	return true
+}
+
+// inputIsTerminal reports whether the input is a terminal, in which case the
+// user's typing is echoed by the terminal itself. The caller must hold
+// o.promptMu.
+func (o *Output) inputIsTerminal() bool {
+	f, ok := o.input().(*os.File)
+	return ok && isTerminal(f)
+}
+
+// pauseLive erases the live area, if any, and keeps live areas from being
+// drawn until the returned function is called, so that a prompt can use the
+// bottom of the terminal without its question being drawn over. The caller
+// must hold o.promptMu.
+func (o *Output) pauseLive() (resume func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.live != nil {
+		o.live.clear()
+	}
+	o.prompting = true
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.prompting = false
+		if o.live != nil && o.live.drawn == 0 {
+			o.live.redraw()
+		}
+	}
+}
+
+// readLine reads one line of input without its line ending. When the input
+// is not a terminal the answer is echoed so the transcript stays readable,
+// unless it is secret, in which case only the line ending is written. The
+// caller must hold o.promptMu.
+func (o *Output) readLine(secret bool) (string, error) {
+	in := o.input()
+	if o.lineReader == nil {
+		o.lineReader = bufio.NewReader(in)
+	}
+	line, err := o.lineReader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !o.inputIsTerminal() {
+		echo := line
+		if secret {
+			echo = ""
+		}
+		o.mu.Lock()
+		_, _ = io.WriteString(o.writerFor(LevelWarn), echo+"\n")
+		o.mu.Unlock()
+	}
+	return line, nil
+}
+
+// writePrompt writes a question followed by a hint, without a trailing
+// newline, to the Warn destination (stderr by default) so that it stays
+// visible when stdout is redirected. The question uses the theme's
+// WarnColor and the hint its DebugColor.
+func (o *Output) writePrompt(question, hint string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	colorEnabled := o.colorFor(LevelWarn)
+	line := o.renderPrefix(colorEnabled) + o.paint(o.messageColorFor(LevelWarn, false), question, colorEnabled)
+	if hint != "" {
+		line += " " + o.paint(o.theme.DebugColor, hint, colorEnabled)
+	}
+	_, _ = io.WriteString(o.writerFor(LevelWarn), line+" ")
+}
+
+// writePromptError writes a validation message for an invalid answer in the
+// theme's ErrorColor, on the prompt's destination.
+func (o *Output) writePromptError(msg string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	colorEnabled := o.colorFor(LevelWarn)
+	line := o.renderPrefix(colorEnabled) + o.paint(o.messageColorFor(LevelError, false), msg, colorEnabled)
+	_, _ = io.WriteString(o.writerFor(LevelWarn), line+"\n")
+}
+
+// Confirm asks a yes/no question and returns the answer. An empty answer
+// selects def, and the hint shows the default in upper case ("[Y/n]" or
+// "[y/N]"). Answers other than y, yes, n or no (in any case) are rejected
+// and the question is asked again.
+//
+// If the input is a file that is not a terminal, Confirm returns def and
+// ErrNotInteractive without reading. If the input ends before an answer is
+// given, it returns def and io.EOF.
+func (o *Output) Confirm(question string, def bool) (bool, error) {
+	o.promptMu.Lock()
+	defer o.promptMu.Unlock()
+
+	if !o.interactive() {
+		return def, ErrNotInteractive
+	}
+	defer o.pauseLive()()
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	for {
+		o.writePrompt(question, hint)
+		answer, err := o.readLine(false)
+		if err != nil {
+			o.endPrompt()
+			return def, err
+		}
+		switch toLower(strings.TrimSpace(answer)) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		o.writePromptError("please answer y or n")
+	}
+}
+
+// endPrompt finishes an unanswered prompt line so later output starts on a
+// fresh line.
+func (o *Output) endPrompt() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, _ = io.WriteString(o.writerFor(LevelWarn), "\n")
+}
@@ -0,0 +1,151 @@
+package cliout
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrThemeExists is returned by RegisterTheme and RegisterThemeAlias when
+// the name is already used by a built-in theme, a registered theme or an
+// alias.
+var ErrThemeExists = errors.New("cliout: theme name already in use")
+
+// registry holds the themes and aliases added at run time.
+var registry struct {
+	mu      sync.RWMutex
+	themes  []Theme
+	aliases map[string]string // lower-cased alias -> theme name
+}
+
+// RegisterTheme adds t to the themes listed by Themes and found by
+// ThemeByName, so that it can also be selected with the CLI_THEME
+// environment variable. Register themes before calling New, for example in
+// an init function. The package-level default output is created before
+// any theme can be registered, so it switches to a registered theme named
+// by CLI_THEME when it is registered, unless SetTheme has been called.
+//
+// The theme must have a name that is not already used, ignoring case, by a
+// built-in theme, another registered theme or an alias; otherwise an error
+// wrapping ErrThemeExists is returned. RegisterTheme is safe for concurrent
+// use.
+func RegisterTheme(t Theme) error {
+	if t.Name == "" {
+		return errors.New("cliout: a registered theme needs a name")
+	}
+	if err := addTheme(t); err != nil {
+		return err
+	}
+	defaultOutput.adoptTheme()
+	return nil
+}
+
+// RegisterThemeAlias makes alias another name for the built-in or
+// registered theme called name, so that ThemeByName and CLI_THEME accept
+// it as they do with RegisterTheme; for example,
+// RegisterThemeAlias("mocha", "Catppuccino Mocha"). The alias must not
+// already be used as a theme name or alias; otherwise an error wrapping
+// ErrThemeExists is returned. Aliases are not listed by Themes.
+// RegisterThemeAlias is safe for concurrent use.
+func RegisterThemeAlias(alias, name string) error {
+	if alias == "" {
+		return errors.New("cliout: a theme alias cannot be empty")
+	}
+	if err := addAlias(alias, name); err != nil {
+		return err
+	}
+	defaultOutput.adoptTheme()
+	return nil
+}
+
+// addTheme adds t to the registry.
+func addTheme(t Theme) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if err := nameInUseLocked(t.Name); err != nil {
+		return err
+	}
+	registry.themes = append(registry.themes, t)
+	return nil
+}
+
+// addAlias adds alias for the theme called name to the registry.
+func addAlias(alias, name string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	t, ok := themeByNameLocked(name)
+	if !ok {
+		return fmt.Errorf("cliout: no theme named %q", name)
+	}
+	if err := nameInUseLocked(alias); err != nil {
+		return err
+	}
+	if registry.aliases == nil {
+		registry.aliases = make(map[string]string)
+	}
+	registry.aliases[toLower(alias)] = t.Name
+	return nil
+}
+
+// UnregisterTheme removes the registered theme or alias with the given
+// name, ignoring case, and reports whether one was removed. Removing a
+// theme also removes its aliases. Built-in themes cannot be removed.
+// UnregisterTheme is safe for concurrent use.
+func UnregisterTheme(name string) bool {
+	lower := toLower(name)
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.aliases[lower]; ok {
+		delete(registry.aliases, lower)
+		return true
+	}
+	for i, t := range registry.themes {
+		if toLower(t.Name) != lower {
+			continue
+		}
+		registry.themes = append(registry.themes[:i:i], registry.themes[i+1:]...)
+		for alias, target := range registry.aliases {
+			if toLower(target) == lower {
+				delete(registry.aliases, alias)
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// themeByNameLocked implements ThemeByName. The caller must hold
+// registry.mu.
+func themeByNameLocked(name string) (Theme, bool) {
+	lower := toLower(name)
+	if target, ok := registry.aliases[lower]; ok {
+		lower = toLower(target)
+	}
+	for _, t := range append(builtinThemes(), registry.themes...) {
+		if toLower(t.Name) == lower {
+			return t, true
+		}
+	}
+	return Theme{}, false
+}
+
+// nameInUseLocked returns an error wrapping ErrThemeExists if name is
+// already a theme name or alias. The caller must hold registry.mu.
+func nameInUseLocked(name string) error {
+	lower := toLower(name)
+	_, isAlias := registry.aliases[lower]
+	if isAlias {
+		return fmt.Errorf("%w: %q is an alias", ErrThemeExists, name)
+	}
+	for _, t := range builtinThemes() {
+		if toLower(t.Name) == lower {
+			return fmt.Errorf("%w: %q is a built-in theme", ErrThemeExists, name)
+		}
+	}
+	for _, t := range registry.themes {
+		if toLower(t.Name) == lower {
+			return fmt.Errorf("%w: %q is already registered", ErrThemeExists, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,467 @@
+package cliout
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// selectPageSize is the number of options shown at once by an interactive
+// menu; longer lists scroll with the cursor.
+const selectPageSize = 10
+
+// Control sequences that hide the cursor while a menu is drawn.
+const (
+	ansiHideCursor = "\033[?25l"
+	ansiShowCursor = "\033[?25h"
+)
+
+// errNoOptions is returned by Select and MultiSelect when given no options.
+var errNoOptions = errors.New("cliout: no options to choose from")
+
+// Select asks the user to choose one of options and returns its index.
+//
+// When the input is a terminal the options are shown as a menu: the arrow
+// keys (or Ctrl-P and Ctrl-N) move the cursor, which is highlighted in the
+// theme's PrefixColor, typing filters the list, Backspace and Esc edit the
+// filter and Enter chooses. Ctrl-C returns ErrInterrupted. Menus need raw
+// terminal mode, which is implemented on Linux, macOS and the BSDs, and a
+// terminal that understands escape sequences. Elsewhere, on terminals whose
+// color profile is ProfileNone (such as TERM=dumb), and when the input is a
+// reader other than a file, as set with SetReader, the options are printed
+// as a numbered list and the answer is read as a line.
+//
+// Like Confirm, Select returns ErrNotInteractive without reading if the
+// input is a file that is not a terminal.
+func (o *Output) Select(question string, options []string) (int, error) {
+	chosen, err := o.choose(question, options, false, nil)
+	if err != nil {
+		return -1, err
+	}
+	return chosen[0], nil
+}
+
+// MultiSelect asks the user to choose any number of options and returns
+// their indexes in ascending order. It works like Select, except that Space
+// toggles the option under the cursor and Enter accepts the current
+// choices. In the numbered-list fallback, answers are separated by commas
+// or spaces and an empty answer chooses nothing.
+func (o *Output) MultiSelect(question string, options []string) ([]int, error) {
+	return o.choose(question, options, true, nil)
+}
+
+// choose runs a menu or its numbered-list fallback. The options at the
+// indexes in defaults start out chosen (for MultiSelect) or under the cursor
+// (for Select), and an empty numbered-list answer selects them.
+func (o *Output) choose(question string, options []string, multi bool, defaults []int) ([]int, error) {
+	if len(options) == 0 {
+		return nil, errNoOptions
+	}
+	o.promptMu.Lock()
+	defer o.promptMu.Unlock()
+
+	if !o.interactive() {
+		return nil, ErrNotInteractive
+	}
+	defer o.pauseLive()()
+
+	if f, ok := o.input().(*os.File); ok && isTerminal(f) && o.ColorProfile() != ProfileNone {
+		if restore, err := makeRaw(f.Fd()); err == nil {
+			defer restore()
+			defer restoreOnSignal(restore)()
+			m := newMenu(options, multi)
+			m.preselect(defaults)
+			return o.runMenu(f, question, m)
+		}
+	}
+	return o.chooseNumbered(question, options, multi, defaults)
+}
+
+// runMenu reads keys from r and redraws m until the user chooses, cancels
+// or the input ends. The terminal must already be in raw mode. The caller
+// must hold o.promptMu.
+func (o *Output) runMenu(r io.Reader, question string, m *menu) ([]int, error) {
+	drawn := 0
+	draw := func(lines []string, final bool) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		w := o.writerFor(LevelWarn)
+		if width := o.widthFor(LevelWarn); width > 0 && !final {
+			// A wrapped line would take more lines than eraseLines clears
+			// on the next draw. The final summary is never erased.
+			for i, line := range lines {
+				lines[i] = truncate(line, width)
+			}
+		}
+		out := eraseLines(drawn) + strings.Join(lines, "\n")
+		if final {
+			out += "\n" + ansiShowCursor
+		}
+		_, _ = io.WriteString(w, out)
+		drawn = len(lines)
+	}
+
+	o.mu.Lock()
+	_, _ = io.WriteString(o.writerFor(LevelWarn), ansiHideCursor)
+	o.mu.Unlock()
+	draw(o.menuLines(question, m), false)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, k := range parseKeys(buf[:n]) {
+			done, kerr := m.handle(k)
+			if kerr != nil {
+				draw([]string{o.menuSummary(question, nil)}, true)
+				return nil, kerr
+			}
+			if done {
+				draw([]string{o.menuSummary(question, m.names(m.result()))}, true)
+				return m.result(), nil
+			}
+		}
+		if err != nil {
+			draw([]string{o.menuSummary(question, nil)}, true)
+			return nil, err
+		}
+		draw(o.menuLines(question, m), false)
+	}
+}
+
+// menuLines renders the question and the visible page of options.
+func (o *Output) menuLines(question string, m *menu) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	colorEnabled := o.colorFor(LevelWarn)
+	prefix := o.renderPrefix(colorEnabled)
+	indent := strings.Repeat(" ", visibleWidth(o.renderPrefix(false)))
+	highlight := o.currentPrefixColor()
+	text := o.messageColorFor(LevelInfo, false)
+	dim := o.theme.DebugColor
+
+	header := prefix + o.paint(o.messageColorFor(LevelWarn, false), question, colorEnabled) + " "
+	if len(m.filter) > 0 {
+		header += o.paint(text, string(m.filter), colorEnabled)
+	} else if m.multi {
+		header += o.paint(dim, "(↑/↓ to move, space to toggle, type to filter)", colorEnabled)
+	} else {
+		header += o.paint(dim, "(↑/↓ to move, type to filter)", colorEnabled)
+	}
+	lines := []string{header}
+
+	if len(m.matches) == 0 {
+		return append(lines, indent+"  "+o.paint(dim, "no matches", colorEnabled))
+	}
+	end := min(m.offset+selectPageSize, len(m.matches))
+	for i := m.offset; i < end; i++ {
+		idx := m.matches[i]
+		marker, c := "  ", text
+		if i == m.cursor {
+			marker, c = o.paint(highlight, "❯", colorEnabled)+" ", highlight
+		}
+		box := ""
+		if m.multi {
+			box = "[ ] "
+			if m.chosen[idx] {
+				box = "[x] "
+			}
+		}
+		lines = append(lines, indent+marker+o.paint(c, box+m.options[idx], colorEnabled))
+	}
+	return lines
+}
+
+// menuSummary renders the line left behind once a menu closes: the question
+// followed by the chosen options in the theme's PrefixColor.
+func (o *Output) menuSummary(question string, names []string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	colorEnabled := o.colorFor(LevelWarn)
+	line := o.renderPrefix(colorEnabled) + o.paint(o.messageColorFor(LevelWarn, false), question, colorEnabled)
+	if len(names) > 0 {
+		line += " " + o.paint(o.currentPrefixColor(), strings.Join(names, ", "), colorEnabled)
+	}
+	return line
+}
+
+// chooseNumbered prints options as a numbered list and reads the chosen
+// numbers as a line, asking again until the answer is valid. The caller
+// must hold o.promptMu.
+func (o *Output) chooseNumbered(question string, options []string, multi bool, defaults []int) ([]int, error) {
+	o.mu.Lock()
+	colorEnabled := o.colorFor(LevelWarn)
+	var b strings.Builder
+	b.WriteString(o.renderPrefix(colorEnabled) + o.paint(o.messageColorFor(LevelWarn, false), question, colorEnabled) + "\n")
+	indent := strings.Repeat(" ", visibleWidth(o.renderPrefix(false)))
+	width := len(strconv.Itoa(len(options)))
+	for i, opt := range options {
+		num := fmt.Sprintf("%*d)", width, i+1)
+		b.WriteString(indent + "  " + o.paint(o.currentPrefixColor(), num, colorEnabled) + " " +
+			o.paint(o.messageColorFor(LevelInfo, false), opt, colorEnabled) + "\n")
+	}
+	_, _ = io.WriteString(o.writerFor(LevelWarn), b.String())
+	o.mu.Unlock()
+
+	label, hint := "Choice", fmt.Sprintf("[1-%d]", len(options))
+	if multi {
+		label, hint = "Choices", fmt.Sprintf("[1-%d, separated by commas]", len(options))
+	}
+	if len(defaults) > 0 {
+		nums := make([]string, len(defaults))
+		for i, d := range defaults {
+			nums[i] = strconv.Itoa(d + 1)
+		}
+		hint = hint[:len(hint)-1] + ", default " + strings.Join(nums, ",") + "]"
+	}
+	for {
+		o.writePrompt(label, hint)
+		answer, err := o.readLine(false)
+		if err != nil {
+			o.endPrompt()
+			return nil, err
+		}
+		if strings.TrimSpace(answer) == "" && len(defaults) > 0 {
+			return defaults, nil
+		}
+		chosen, ok := parseChoices(answer, len(options), multi)
+		if ok {
+			return chosen, nil
+		}
+		if multi {
+			o.writePromptError(fmt.Sprintf("please enter numbers between 1 and %d", len(options)))
+		} else {
+			o.writePromptError(fmt.Sprintf("please enter a number between 1 and %d", len(options)))
+		}
+	}
+}
+
+// parseChoices parses a numbered-list answer into zero-based indexes in
+// ascending order. A single choice is required unless multi is set, in
+// which case any number, including none, is accepted.
+func parseChoices(answer string, n int, multi bool) ([]int, bool) {
+	parts := strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	if !multi && len(parts) != 1 {
+		return nil, false
+	}
+	seen := map[int]bool{}
+	chosen := []int{}
+	for _, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 1 || v > n {
+			return nil, false
+		}
+		if !seen[v-1] {
+			seen[v-1] = true
+			chosen = append(chosen, v-1)
+		}
+	}
+	sort.Ints(chosen)
+	return chosen, true
+}
+
+// keyKind identifies a key read from a terminal in raw mode.
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyUp
+	keyDown
+	keyEnter
+	keyBackspace
+	keyEscape
+	keyInterrupt
+	keyEOF
+)
+
+// key is a single key press; r is set for keyRune.
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// parseKeys splits raw terminal input into key presses. Escape sequences
+// other than the up and down arrows, and control characters without a
+// meaning in menus, are dropped.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == 0x1b:
+			if i+2 < len(b) && (b[i+1] == '[' || b[i+1] == 'O') {
+				switch b[i+2] {
+				case 'A':
+					keys = append(keys, key{kind: keyUp})
+					i += 3
+					continue
+				case 'B':
+					keys = append(keys, key{kind: keyDown})
+					i += 3
+					continue
+				}
+			}
+			if n := ansiLen(string(b[i:])); n > 0 {
+				i += n
+				continue
+			}
+			if i+2 < len(b) && b[i+1] == 'O' {
+				i += 3
+				continue
+			}
+			keys = append(keys, key{kind: keyEscape})
+		case c == '\r' || c == '\n':
+			keys = append(keys, key{kind: keyEnter})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{kind: keyBackspace})
+		case c == 0x03:
+			keys = append(keys, key{kind: keyInterrupt})
+		case c == 0x04:
+			keys = append(keys, key{kind: keyEOF})
+		case c == 0x10:
+			keys = append(keys, key{kind: keyUp})
+		case c == 0x0e:
+			keys = append(keys, key{kind: keyDown})
+		case c < 0x20:
+			// Other control characters have no meaning in a menu.
+		default:
+			r, size := utf8.DecodeRune(b[i:])
+			keys = append(keys, key{kind: keyRune, r: r})
+			i += size
+			continue
+		}
+		i++
+	}
+	return keys
+}
+
+// menu is the state of an interactive Select or MultiSelect.
+type menu struct {
+	options []string
+	multi   bool
+	filter  []rune
+	matches []int // indexes of the options that match the filter
+	cursor  int   // position in matches
+	offset  int   // first position in matches that is shown
+	chosen  map[int]bool
+}
+
+// newMenu creates a menu with the cursor on the first option.
+func newMenu(options []string, multi bool) *menu {
+	m := &menu{options: options, multi: multi, chosen: map[int]bool{}}
+	m.applyFilter()
+	return m
+}
+
+// preselect moves the cursor to the first of defaults and, in a
+// MultiSelect, marks them all as chosen. Out-of-range indexes are ignored.
+func (m *menu) preselect(defaults []int) {
+	first := true
+	for _, d := range defaults {
+		if d < 0 || d >= len(m.options) {
+			continue
+		}
+		if m.multi {
+			m.chosen[d] = true
+		}
+		if first {
+			m.cursor = d
+			if m.cursor >= selectPageSize {
+				m.offset = m.cursor - selectPageSize + 1
+			}
+			first = false
+		}
+	}
+}
+
+// applyFilter recomputes the options matching the filter, which is a
+// case-insensitive substring, and moves the cursor to the first of them.
+func (m *menu) applyFilter() {
+	needle := toLower(string(m.filter))
+	m.matches = m.matches[:0]
+	for i, opt := range m.options {
+		if strings.Contains(toLower(opt), needle) {
+			m.matches = append(m.matches, i)
+		}
+	}
+	m.cursor, m.offset = 0, 0
+}
+
+// handle applies a key press and reports whether the menu is finished.
+func (m *menu) handle(k key) (done bool, err error) {
+	switch k.kind {
+	case keyUp, keyDown:
+		if len(m.matches) == 0 {
+			return false, nil
+		}
+		if k.kind == keyUp {
+			m.cursor = (m.cursor - 1 + len(m.matches)) % len(m.matches)
+		} else {
+			m.cursor = (m.cursor + 1) % len(m.matches)
+		}
+		if m.cursor < m.offset {
+			m.offset = m.cursor
+		} else if m.cursor >= m.offset+selectPageSize {
+			m.offset = m.cursor - selectPageSize + 1
+		}
+	case keyEnter:
+		if m.multi {
+			return true, nil
+		}
+		if len(m.matches) == 0 {
+			return false, nil
+		}
+		m.chosen = map[int]bool{m.matches[m.cursor]: true}
+		return true, nil
+	case keyBackspace:
+		if len(m.filter) > 0 {
+			m.filter = m.filter[:len(m.filter)-1]
+			m.applyFilter()
+		}
+	case keyEscape:
+		if len(m.filter) > 0 {
+			m.filter = nil
+			m.applyFilter()
+		}
+	case keyInterrupt:
+		return false, ErrInterrupted
+	case keyEOF:
+		return false, io.EOF
+	case keyRune:
+		if m.multi && k.r == ' ' {
+			if len(m.matches) > 0 {
+				idx := m.matches[m.cursor]
+				m.chosen[idx] = !m.chosen[idx]
+			}
+			return false, nil
+		}
+		m.filter = append(m.filter, k.r)
+		m.applyFilter()
+	}
+	return false, nil
+}
+
+// result returns the chosen indexes in ascending order.
+func (m *menu) result() []int {
+	chosen := []int{}
+	for i, ok := range m.chosen {
+		if ok {
+			chosen = append(chosen, i)
+		}
+	}
+	sort.Ints(chosen)
+	return chosen
+}
+
+// names returns the options at the given indexes.
+func (m *menu) names(indexes []int) []string {
+	names := make([]string, len(indexes))
+	for i, idx := range indexes {
+		names[i] = m.options[idx]
+	}
+	return names
+}
@@ -0,0 +1,126 @@
+package cliout
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// spinnerFrames are the animation frames drawn in place of the prefix.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// spinnerInterval is the delay between animation frames.
+var spinnerInterval = 80 * time.Millisecond
+
+// Spinner is an animated activity indicator created by Output.Spinner or
+// MultiProgress.Spinner.
+//
+// On a terminal the spinner is drawn in place of the prefix, using the
+// theme's PrefixColor, and redrawn until it is finished. When the Info
+// destination is not a terminal, or color is disabled, the spinner degrades
+// to a single start line and a single end line so logs stay clean.
+//
+// Finish a spinner with exactly one of Success, Warn, Error or Stop. A
+// Spinner is safe for concurrent use.
+type Spinner struct {
+	task
+	msg   string
+	frame int
+}
+
+// Spinner starts a spinner showing msg at info level and returns it. Lines
+// printed on the same Output while the spinner is running are written above
+// it.
+func (o *Output) Spinner(msg string) *Spinner {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return newSpinner(o, nil, msg)
+}
+
+// newSpinner creates and shows a spinner. The caller must hold o.mu.
+func newSpinner(o *Output, m *MultiProgress, msg string) *Spinner {
+	s := &Spinner{msg: msg}
+	s.task = task{o: o, row: s, multi: m}
+	s.begin(msg)
+	return s
+}
+
+// Update replaces the spinner's message. When the spinner is not animated
+// the new message is not printed.
+func (s *Spinner) Update(msg string) {
+	s.o.mu.Lock()
+	defer s.o.mu.Unlock()
+	s.msg = msg
+	if s.animated() {
+		s.area.refresh()
+	}
+}
+
+// Updatef replaces the spinner's message with a formatted message.
+func (s *Spinner) Updatef(format string, a ...any) {
+	s.Update(fmt.Sprintf(format, a...))
+}
+
+// Success finishes the spinner and prints msg as a success message.
+func (s *Spinner) Success(msg string) {
+	s.finish(LevelInfo, msg, true)
+}
+
+// Successf finishes the spinner and prints a formatted message as a success message.
+func (s *Spinner) Successf(format string, a ...any) {
+	s.finish(LevelInfo, fmt.Sprintf(format, a...), true)
+}
+
+// Warn finishes the spinner and prints msg as a warn-level message.
+func (s *Spinner) Warn(msg string) {
+	s.finish(LevelWarn, msg, false)
+}
+
+// Warnf finishes the spinner and prints a formatted message as a warn-level message.
+func (s *Spinner) Warnf(format string, a ...any) {
+	s.finish(LevelWarn, fmt.Sprintf(format, a...), false)
+}
+
+// Error finishes the spinner and prints msg as an error-level message.
+func (s *Spinner) Error(msg string) {
+	s.finish(LevelError, msg, false)
+}
+
+// Errorf finishes the spinner and prints a formatted message as an error-level message.
+func (s *Spinner) Errorf(format string, a ...any) {
+	s.finish(LevelError, fmt.Sprintf(format, a...), false)
+}
+
+// Stop finishes the spinner and erases it without printing a final line.
+func (s *Spinner) Stop() {
+	s.o.mu.Lock()
+	defer s.o.mu.Unlock()
+	s.end(LevelInfo, "", false, false)
+}
+
+// finish erases the spinner and prints the final line.
+func (s *Spinner) finish(level Level, msg string, isSuccess bool) {
+	s.o.mu.Lock()
+	defer s.o.mu.Unlock()
+	s.end(level, msg, isSuccess, true)
+}
+
+// tick implements liveRow.
+func (s *Spinner) tick() bool {
+	s.frame = (s.frame + 1) % len(spinnerFrames)
+	return true
+}
+
+// render implements liveRow. Newlines in the message are drawn as spaces so
+// that the spinner stays on the one line the live area erases.
+func (s *Spinner) render() string {
+	o := s.o
+	frame := o.paint(o.currentPrefixColor(), spinnerFrames[s.frame], true)
+	msg := o.paint(o.messageColorFor(LevelInfo, false), flattenLines(s.msg), true)
+	return frame + " " + o.renderIndent(true) + msg
+}
+
+// flattenLines replaces the line breaks in s with spaces.
+func flattenLines(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,289 @@
+package cliout
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Align controls how text is positioned within a column.
+type Align int
+
+const (
+	// AlignLeft pads text on the right. This is the default.
+	AlignLeft Align = iota
+	// AlignRight pads text on the left, which suits numbers.
+	AlignRight
+	// AlignCenter pads text evenly on both sides.
+	AlignCenter
+)
+
+// BorderStyle selects the characters used to draw a table's borders.
+type BorderStyle int
+
+const (
+	// BorderNone separates columns with spaces and draws no lines.
+	BorderNone BorderStyle = iota
+	// BorderASCII draws borders with +, - and |.
+	BorderASCII
+	// BorderUnicode draws borders with Unicode box-drawing characters.
+	BorderUnicode
+)
+
+// borderChars holds the glyphs for one border style. Corners and junctions
+// are indexed top, middle (below the header) and bottom.
+type borderChars struct {
+	horizontal string
+	vertical   string
+	left       [3]string
+	cross      [3]string
+	right      [3]string
+}
+
+var borders = map[BorderStyle]borderChars{
+	BorderASCII: {
+		horizontal: "-",
+		vertical:   "|",
+		left:       [3]string{"+", "+", "+"},
+		cross:      [3]string{"+", "+", "+"},
+		right:      [3]string{"+", "+", "+"},
+	},
+	BorderUnicode: {
+		horizontal: "─",
+		vertical:   "│",
+		left:       [3]string{"┌", "├", "└"},
+		cross:      [3]string{"┬", "┼", "┴"},
+		right:      [3]string{"┐", "┤", "┘"},
+	},
+}
+
+// minColumnWidth is the narrowest a column is shrunk to when truncating a
+// table to fit the terminal.
+const minColumnWidth = 3
+
+// Table renders rows of data in aligned columns, created by Output.Table.
+//
+// Column widths are computed from the visible width of each cell, so cells
+// colored with Colorize line up correctly. Headers use the theme's
+// PrefixColor, borders use DebugColor and cells use InfoColor unless given
+// their own color with SetCellColor or SetColumnColor. Each line of the
+// table is printed at info level with the Output's prefix. In JSON mode
+// each row is instead emitted as an object whose fields are keyed by the
+// column headers.
+type Table struct {
+	o           *Output
+	headers     []string
+	rows        [][]string
+	aligns      map[int]Align
+	colColors   map[int]Color
+	cellColors  map[[2]int]Color
+	border      BorderStyle
+	maxWidth    int
+	hasMaxWidth bool
+}
+
+// Table creates an empty table with the given column headers. Add rows with
+// AddRow and print it with Render.
+func (o *Output) Table(headers ...string) *Table {
+	return &Table{
+		o:          o,
+		headers:    headers,
+		aligns:     map[int]Align{},
+		colColors:  map[int]Color{},
+		cellColors: map[[2]int]Color{},
+	}
+}
+
+// AddRow appends a row of cells. Rows may have fewer or more cells than
+// there are headers; missing cells are left blank.
+func (t *Table) AddRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// SetAlign sets the alignment of a column (zero-based).
+func (t *Table) SetAlign(col int, a Align) {
+	t.aligns[col] = a
+}
+
+// SetBorder sets the border style. The default is BorderNone.
+func (t *Table) SetBorder(b BorderStyle) {
+	t.border = b
+}
+
+// SetColumnColor sets the color of every cell in a column (zero-based),
+// overriding the theme's InfoColor.
+func (t *Table) SetColumnColor(col int, c Color) {
+	t.colColors[col] = c
+}
+
+// SetCellColor sets the color of a single cell, overriding any column
+// color. Row and column are zero-based indexes into the rows added with
+// AddRow.
+func (t *Table) SetCellColor(row, col int, c Color) {
+	t.cellColors[[2]int{row, col}] = c
+}
+
+// SetMaxWidth sets the maximum width of the table in columns, including the
+// Output's prefix. Cells in the widest columns are truncated with an
+// ellipsis to fit. A width of zero or less disables truncation. By default
+// the Output's width is used (see Output.SetWidth).
+func (t *Table) SetMaxWidth(n int) {
+	t.maxWidth = n
+	t.hasMaxWidth = true
+}
+
+// Render prints the table.
+func (t *Table) Render() {
+	o := t.o
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if LevelInfo < o.level {
+		return
+	}
+	if o.format == FormatJSON {
+		t.renderJSON()
+		return
+	}
+	for _, line := range t.lines() {
+		o.printRenderedLocked(LevelInfo, line)
+	}
+}
+
+// renderJSON writes one JSON object per row, with the Output's fields
+// followed by the cells as fields keyed by their column header. The caller
+// must hold t.o.mu.
+func (t *Table) renderJSON() {
+	for _, cells := range t.rows {
+		fields := make([]Field, 0, len(t.o.fields)+len(cells))
+		fields = append(fields, t.o.fields...)
+		for i, c := range cells {
+			key := fmt.Sprintf("column%d", i+1)
+			if i < len(t.headers) {
+				key = t.headers[i]
+			}
+			fields = append(fields, F(key, stripANSI(c)))
+		}
+		t.o.writeLine(LevelInfo, jsonLine(LevelInfo, "", false, fields))
+	}
+}
+
+// lines returns the rendered table lines without the prefix. The caller
+// must hold t.o.mu.
+func (t *Table) lines() []string {
+	o := t.o
+	colorEnabled := o.colorFor(LevelInfo)
+	widths := t.columnWidths()
+	chars, boxed := borders[t.border]
+
+	sep := "  "
+	if boxed {
+		sep = " " + o.paint(o.theme.DebugColor, chars.vertical, colorEnabled) + " "
+	}
+	row := func(cells []string, color func(col int) Color) string {
+		parts := make([]string, len(widths))
+		for i, w := range widths {
+			text := ""
+			if i < len(cells) {
+				text = truncate(cells[i], w)
+			}
+			parts[i] = pad(o.paint(color(i), text, colorEnabled), w, t.aligns[i])
+		}
+		line := strings.Join(parts, sep)
+		if boxed {
+			edge := o.paint(o.theme.DebugColor, chars.vertical, colorEnabled)
+			return edge + " " + line + " " + edge
+		}
+		return strings.TrimRight(line, " ")
+	}
+	rule := func(i int) string {
+		parts := make([]string, len(widths))
+		for c, w := range widths {
+			parts[c] = strings.Repeat(chars.horizontal, w+2)
+		}
+		line := chars.left[i] + strings.Join(parts, chars.cross[i]) + chars.right[i]
+		return o.paint(o.theme.DebugColor, line, colorEnabled)
+	}
+
+	var lines []string
+	if boxed {
+		lines = append(lines, rule(0))
+	}
+	if len(t.headers) > 0 {
+		lines = append(lines, row(t.headers, func(int) Color { return o.currentPrefixColor() }))
+		if boxed {
+			lines = append(lines, rule(1))
+		}
+	}
+	for r, cells := range t.rows {
+		lines = append(lines, row(cells, func(col int) Color { return t.cellColor(r, col) }))
+	}
+	if boxed {
+		lines = append(lines, rule(2))
+	}
+	return lines
+}
+
+// cellColor returns the color for a data cell. The caller must hold t.o.mu.
+func (t *Table) cellColor(row, col int) Color {
+	if c, ok := t.cellColors[[2]int{row, col}]; ok {
+		return c
+	}
+	if c, ok := t.colColors[col]; ok {
+		return c
+	}
+	return t.o.messageColorFor(LevelInfo, false)
+}
+
+// columnWidths returns the visible width of each column, shrinking the
+// widest columns if the table would exceed its maximum width. The caller
+// must hold t.o.mu.
+func (t *Table) columnWidths() []int {
+	n := len(t.headers)
+	for _, r := range t.rows {
+		if len(r) > n {
+			n = len(r)
+		}
+	}
+	widths := make([]int, n)
+	measure := func(cells []string) {
+		for i, c := range cells {
+			if w := visibleWidth(c); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	measure(t.headers)
+	for _, r := range t.rows {
+		measure(r)
+	}
+
+	limit := t.maxWidth
+	if !t.hasMaxWidth {
+		limit = t.o.widthFor(LevelInfo)
+	}
+	if limit <= 0 || n == 0 {
+		return widths
+	}
+	avail := limit - visibleWidth(t.o.renderPrefix(false)) - t.overhead(n)
+	for {
+		total, widest := 0, 0
+		for i, w := range widths {
+			total += w
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if total <= avail || widths[widest] <= minColumnWidth {
+			return widths
+		}
+		widths[widest]--
+	}
+}
+
+// overhead returns the number of columns used by separators and borders for
+// a table with n columns.
+func (t *Table) overhead(n int) int {
+	if _, boxed := borders[t.border]; boxed {
+		return 3*n + 1
+	}
+	return 2 * (n - 1)
+}
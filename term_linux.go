@@ -0,0 +1,11 @@
+//go:build linux
+
+package cliout
+
+import "syscall"
+
+// The ioctl requests that get and set terminal attributes.
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
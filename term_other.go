@@ -0,0 +1,32 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package cliout
+
+import "errors"
+
+// errRawUnsupported is returned by makeRaw on platforms where raw terminal
+// mode is not implemented. Prompts fall back to line-based input.
+var errRawUnsupported = errors.New("cliout: raw terminal mode is not supported on this platform")
+
+// hasTermios cannot check terminal attributes on this platform, so every
+// character device is treated as a terminal.
+func hasTermios(fd uintptr) bool {
+	return true
+}
+
+// windowWidth cannot query the terminal on this platform, so terminal
+// widths come from the COLUMNS environment variable alone.
+func windowWidth(fd uintptr) int {
+	return 0
+}
+
+// makeRaw is not implemented on this platform.
+func makeRaw(fd uintptr) (restore func(), err error) {
+	return nil, errRawUnsupported
+}
+
+// restoreOnSignal has nothing to do on this platform, where makeRaw always
+// fails.
+func restoreOnSignal(restore func()) (stop func()) {
+	return func() {}
+}
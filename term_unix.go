@@ -0,0 +1,81 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package cliout
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw puts the terminal on fd into raw mode, so that keys are delivered
+// one at a time without echo or line editing, and returns a function that
+// restores the previous mode. The restore function may be called more than
+// once. Output post-processing is left enabled so "\n" still starts a new
+// line.
+func makeRaw(fd uintptr) (restore func(), err error) {
+	var old syscall.Termios
+	if err := ioctlTermios(fd, ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctlTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() { once.Do(func() { _ = ioctlTermios(fd, ioctlSetTermios, &old) }) }, nil
+}
+
+// hasTermios reports whether fd has terminal attributes, that is, whether it
+// is a terminal rather than some other character device.
+func hasTermios(fd uintptr) bool {
+	var t syscall.Termios
+	return ioctlTermios(fd, ioctlGetTermios, &t) == nil
+}
+
+// windowWidth returns the width in columns of the terminal on fd, or 0 if
+// it cannot be determined.
+func windowWidth(fd uintptr) int {
+	var ws struct{ Row, Col, Xpixel, Ypixel uint16 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 {
+		return 0
+	}
+	return int(ws.Col)
+}
+
+// ioctlTermios gets or sets the terminal attributes of fd.
+func ioctlTermios(fd, req uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// restoreOnSignal calls restore if SIGINT or SIGTERM arrives before the
+// returned stop function is called, then re-raises the signal so the
+// program still terminates as it would have. It keeps a terminal from being
+// left in raw mode when the process is killed during a prompt.
+func restoreOnSignal(restore func()) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-ch:
+			restore()
+			signal.Stop(ch)
+			_ = syscall.Kill(syscall.Getpid(), sig.(syscall.Signal))
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
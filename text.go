@@ -0,0 +1,402 @@
+package cliout
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ellipsis marks text that has been truncated to fit.
+const ellipsis = "…"
+
+// writerWidth returns the width in columns of the terminal w writes to,
+// asking the terminal itself and falling back to the COLUMNS environment
+// variable if that fails. It returns 0 if w is not a terminal, so that
+// output piped to files and other programs is never wrapped, or if the
+// width is unknown.
+func writerWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok || !isTerminal(f) {
+		return 0
+	}
+	if n := windowWidth(f.Fd()); n > 0 {
+		return n
+	}
+	return terminalWidth()
+}
+
+// terminalWidth returns the width of the terminal in columns, or 0 if it is
+// unknown. It is taken from the COLUMNS environment variable.
+func terminalWidth() int {
+	if v, ok := os.LookupEnv("COLUMNS"); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// ansiLen returns the length of the ANSI escape sequence at the start of s,
+// or 0 if s does not start with one. Only CSI sequences (ESC [ ... final
+// byte) are recognised, which covers everything Color.apply emits.
+func ansiLen(s string) int {
+	if len(s) < 2 || s[0] != '\033' || s[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7E {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// stripANSI returns s with all ANSI escape sequences removed.
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\033[") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if n := ansiLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// visibleWidth returns the number of columns s occupies on a terminal,
+// ignoring ANSI escape sequences and counting wide characters as two.
+func visibleWidth(s string) int {
+	n := 0
+	for _, r := range stripANSI(s) {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// runeWidth returns the number of columns r occupies on a terminal: 0 for
+// control characters, combining marks and other zero-width characters, 2
+// for East Asian wide and fullwidth characters and emoji, and 1 otherwise.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7F && r < 0xA0):
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || (r >= 0xFE00 && r <= 0xFE0F):
+		return 0
+	case inRanges(r, wideRanges):
+		return 2
+	}
+	return 1
+}
+
+// wideRanges lists the code points, in ascending order, that terminals draw
+// two columns wide: East Asian wide and fullwidth characters and the emoji
+// blocks.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x231A, 0x231B},   // watch, hourglass
+	{0x2329, 0x232A},   // angle brackets
+	{0x23E9, 0x23EC},   // media controls
+	{0x23F0, 0x23F0},   // alarm clock
+	{0x23F3, 0x23F3},   // hourglass with flowing sand
+	{0x25FD, 0x25FE},   // small squares
+	{0x2614, 0x2615},   // umbrella, hot beverage
+	{0x2648, 0x2653},   // zodiac
+	{0x267F, 0x267F},   // wheelchair
+	{0x2693, 0x2693},   // anchor
+	{0x26A1, 0x26A1},   // high voltage
+	{0x26AA, 0x26AB},   // circles
+	{0x26BD, 0x26BE},   // balls
+	{0x26C4, 0x26C5},   // snowman, sun behind cloud
+	{0x26CE, 0x26CE},   // ophiuchus
+	{0x26D4, 0x26D4},   // no entry
+	{0x26EA, 0x26EA},   // church
+	{0x26F2, 0x26F3},   // fountain, golf
+	{0x26F5, 0x26F5},   // sailboat
+	{0x26FA, 0x26FA},   // tent
+	{0x26FD, 0x26FD},   // fuel pump
+	{0x2705, 0x2705},   // check mark button
+	{0x270A, 0x270B},   // fists
+	{0x2728, 0x2728},   // sparkles
+	{0x274C, 0x274C},   // cross mark
+	{0x274E, 0x274E},   // cross mark button
+	{0x2753, 0x2755},   // question and exclamation marks
+	{0x2757, 0x2757},   // exclamation mark
+	{0x2795, 0x2797},   // plus, minus, divide
+	{0x27B0, 0x27B0},   // curly loop
+	{0x27BF, 0x27BF},   // double curly loop
+	{0x2B1B, 0x2B1C},   // large squares
+	{0x2B50, 0x2B50},   // star
+	{0x2B55, 0x2B55},   // circle
+	{0x2E80, 0x303E},   // CJK radicals, punctuation
+	{0x3041, 0x33FF},   // kana, CJK symbols
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo extended A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE10, 0xFE19},   // vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms, small forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x16FE0, 0x16FE4}, // ideographic symbols
+	{0x17000, 0x18AFF}, // Tangut
+	{0x1B000, 0x1B2FF}, // kana supplement, Nushu
+	{0x1F004, 0x1F004}, // mahjong tile
+	{0x1F0CF, 0x1F0CF}, // joker
+	{0x1F18E, 0x1F18E}, // AB button
+	{0x1F191, 0x1F19A}, // squared words
+	{0x1F200, 0x1F251}, // enclosed ideographs
+	{0x1F260, 0x1F265}, // rounded symbols
+	{0x1F300, 0x1F320}, // weather, landscape
+	{0x1F32D, 0x1F335}, // food, plants
+	{0x1F337, 0x1F37C}, // plants, food, drink
+	{0x1F37E, 0x1F393}, // celebration
+	{0x1F3A0, 0x1F3CA}, // activities
+	{0x1F3CF, 0x1F3D3}, // sports
+	{0x1F3E0, 0x1F3F0}, // buildings
+	{0x1F3F4, 0x1F3F4}, // black flag
+	{0x1F3F8, 0x1F43E}, // sports, animals
+	{0x1F440, 0x1F440}, // eyes
+	{0x1F442, 0x1F4FC}, // people, objects
+	{0x1F4FF, 0x1F53D}, // objects, symbols
+	{0x1F54B, 0x1F54E}, // religious symbols
+	{0x1F550, 0x1F567}, // clock faces
+	{0x1F57A, 0x1F57A}, // dancing
+	{0x1F595, 0x1F596}, // hands
+	{0x1F5A4, 0x1F5A4}, // black heart
+	{0x1F5FB, 0x1F64F}, // places, faces
+	{0x1F680, 0x1F6C5}, // transport
+	{0x1F6CC, 0x1F6CC}, // sleeping
+	{0x1F6D0, 0x1F6D2}, // religious, shopping
+	{0x1F6D5, 0x1F6D7}, // buildings
+	{0x1F6DC, 0x1F6DF}, // objects
+	{0x1F6EB, 0x1F6EC}, // airplanes
+	{0x1F6F4, 0x1F6FC}, // vehicles
+	{0x1F7E0, 0x1F7EB}, // colored shapes
+	{0x1F7F0, 0x1F7F0}, // heavy equals
+	{0x1F90C, 0x1F93A}, // people, gestures
+	{0x1F93C, 0x1F945}, // sports
+	{0x1F947, 0x1F9FF}, // medals, people, objects
+	{0x1FA70, 0x1FAFF}, // symbols and pictographs extended A
+	{0x20000, 0x2FFFD}, // CJK extensions B to F
+	{0x30000, 0x3FFFD}, // CJK extension G onwards
+}
+
+// inRanges reports whether r falls in one of the sorted, inclusive ranges.
+func inRanges(r rune, ranges [][2]rune) bool {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i][1] >= r })
+	return i < len(ranges) && ranges[i][0] <= r
+}
+
+// truncate shortens s to at most width visible columns, replacing the end
+// with an ellipsis. ANSI escape sequences are preserved, and a reset is
+// appended if any were present so color does not leak past the cut.
+func truncate(s string, width int) string {
+	if visibleWidth(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	seq := false
+	col := 0
+	for i := 0; i < len(s); {
+		if n := ansiLen(s[i:]); n > 0 {
+			b.WriteString(s[i : i+n])
+			seq = true
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		w := runeWidth(r)
+		if col+w > width-1 {
+			break
+		}
+		b.WriteString(s[i : i+size])
+		col += w
+		i += size
+	}
+	b.WriteString(ellipsis)
+	if seq {
+		b.WriteString("\033[0m")
+	}
+	return b.String()
+}
+
+// pad aligns s within width visible columns.
+func pad(s string, width int, align Align) string {
+	gap := width - visibleWidth(s)
+	if gap <= 0 {
+		return s
+	}
+	switch align {
+	case AlignRight:
+		return strings.Repeat(" ", gap) + s
+	case AlignCenter:
+		left := gap / 2
+		return strings.Repeat(" ", left) + s + strings.Repeat(" ", gap-left)
+	default:
+		return s + strings.Repeat(" ", gap)
+	}
+}
+
+// minWrapWidth is the narrowest space messages are wrapped into. When less
+// room than this is left after the prefix, lines are left long rather than
+// broken into slivers.
+const minWrapWidth = 20
+
+// ansiReset ends all SGR attributes.
+const ansiReset = "\033[0m"
+
+// wrap word-wraps s into lines of at most width visible columns, breaking
+// at spaces and splitting words longer than a line. Spaces at a break are
+// dropped. ANSI escape sequences take no space, and any styling still active
+// at a break is reset at the end of the line and reopened at the start of
+// the next, so each line can be written on its own. A width of zero or less
+// disables wrapping.
+func wrap(s string, width int) []string {
+	if width <= 0 || visibleWidth(s) <= width {
+		return []string{s}
+	}
+
+	// Split s into atoms: single runes and whole escape sequences.
+	type atom struct {
+		text  string
+		width int
+		space bool
+		ansi  bool
+	}
+	var atoms []atom
+	for i := 0; i < len(s); {
+		if n := ansiLen(s[i:]); n > 0 {
+			atoms = append(atoms, atom{text: s[i : i+n], ansi: true})
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		atoms = append(atoms, atom{text: s[i : i+size], width: runeWidth(r), space: r == ' '})
+		i += size
+	}
+
+	var lines []string
+	var line strings.Builder
+	col := 0
+	active := "" // SGR sequences in effect since the last reset
+	writeANSI := func(seq string) {
+		line.WriteString(seq)
+		active = trackSGR(active, seq)
+	}
+	breakLine := func() {
+		if active != "" {
+			line.WriteString(ansiReset)
+		}
+		lines = append(lines, line.String())
+		line.Reset()
+		line.WriteString(active)
+		col = 0
+	}
+
+	for i := 0; i < len(atoms); {
+		a := atoms[i]
+		switch {
+		case a.ansi:
+			writeANSI(a.text)
+			i++
+		case a.space:
+			// Measure the run of spaces and the word that follows it.
+			j, spaces := i, 0
+			for ; j < len(atoms) && (atoms[j].space || atoms[j].ansi); j++ {
+				spaces += atoms[j].width
+			}
+			word := 0
+			for k := j; k < len(atoms) && !atoms[k].space; k++ {
+				word += atoms[k].width
+			}
+			fits := col+spaces+word <= width
+			breaks := !fits && col > 0 && j < len(atoms)
+			// Escape sequences among the spaces belong to the next word, so
+			// when the line breaks they open the next line instead of
+			// being left empty at the end of this one.
+			var deferred []string
+			for ; i < j; i++ {
+				switch {
+				case atoms[i].ansi && breaks:
+					deferred = append(deferred, atoms[i].text)
+				case atoms[i].ansi:
+					writeANSI(atoms[i].text)
+				case fits && (col > 0 || len(lines) == 0):
+					line.WriteString(atoms[i].text)
+					col += atoms[i].width
+				}
+			}
+			if breaks {
+				breakLine()
+				for _, seq := range deferred {
+					writeANSI(seq)
+				}
+			}
+		default:
+			if col+a.width > width && col > 0 {
+				breakLine()
+			}
+			line.WriteString(a.text)
+			col += a.width
+			i++
+		}
+	}
+	lines = append(lines, line.String())
+	return lines
+}
+
+// trackSGR returns the SGR sequences in effect after seq is written, given
+// those in effect before it. Escape sequences other than SGR are ignored.
+func trackSGR(active, seq string) string {
+	switch {
+	case seq == ansiReset || seq == "\033[m":
+		return ""
+	case strings.HasSuffix(seq, "m"):
+		return active + seq
+	}
+	return active
+}
+
+// splitLines splits s at newlines, dropping a "\r" before each. Styling
+// still active at the end of a line is reset there and reopened at the
+// start of the next, so each line can be written on its own.
+func splitLines(s string) []string {
+	lines := strings.Split(s, "\n")
+	if len(lines) == 1 {
+		return lines
+	}
+	active := ""
+	for i, raw := range lines {
+		raw = strings.TrimSuffix(raw, "\r")
+		line := active + raw
+		for j := 0; j < len(raw); {
+			if n := ansiLen(raw[j:]); n > 0 {
+				active = trackSGR(active, raw[j:j+n])
+				j += n
+				continue
+			}
+			j++
+		}
+		if active != "" {
+			line += ansiReset
+		}
+		lines[i] = line
+	}
+	return lines
+}
@@ -1,15 +1,16 @@
 package cliout
 
-// Theme defines the colors used for each output level.
+// Theme defines the colors used for each output level. It marshals to and
+// from JSON in the format read by ParseTheme.
 type Theme struct {
-	Name         string
-	PrefixColor  Color
-	InfoColor    Color
-	DebugColor   Color
-	TraceColor   Color
-	WarnColor    Color
-	ErrorColor   Color
-	SuccessColor Color
+	Name         string `json:"name"`
+	PrefixColor  Color  `json:"prefix"`
+	InfoColor    Color  `json:"info"`
+	DebugColor   Color  `json:"debug"`
+	TraceColor   Color  `json:"trace"`
+	WarnColor    Color  `json:"warn"`
+	ErrorColor   Color  `json:"error"`
+	SuccessColor Color  `json:"success"`
 }
 
 // ThemeDefault is a simple theme using standard ANSI colors.
@@ -391,9 +392,19 @@ var ThemeTokyoNightNight = Theme{
 
 // --- Theme listing and lookup ---
 
-// Themes returns a slice containing all built-in themes. The returned slice
-// is a fresh copy each time, so callers are free to modify it.
+// Themes returns a slice containing all built-in themes followed by the
+// themes added with RegisterTheme, in the order they were registered. The
+// returned slice is a fresh copy each time, so callers are free to modify
+// it.
 func Themes() []Theme {
+	themes := builtinThemes()
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return append(themes, registry.themes...)
+}
+
+// builtinThemes returns a fresh slice of the built-in themes.
+func builtinThemes() []Theme {
 	return []Theme{
 		ThemeDefault,
 		ThemeAyu, ThemeAyuLight, ThemeAyuMirage,
@@ -415,17 +426,14 @@ func Themes() []Theme {
 	}
 }
 
-// ThemeByName returns the built-in theme with the given name and true if found,
-// or an empty Theme and false if no match exists. The comparison is
-// case-insensitive.
+// ThemeByName returns the built-in or registered theme with the given name,
+// or the theme an alias registered with RegisterThemeAlias refers to, and
+// true if found, or an empty Theme and false if no match exists. The
+// comparison is case-insensitive.
 func ThemeByName(name string) (Theme, bool) {
-	lower := toLower(name)
-	for _, t := range Themes() {
-		if toLower(t.Name) == lower {
-			return t, true
-		}
-	}
-	return Theme{}, false
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return themeByNameLocked(name)
 }
 
 // toLower returns s with ASCII A-Z mapped to a-z. This avoids importing
@@ -0,0 +1,160 @@
+package cliout
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// themeFile is the JSON form of a Theme. Colors are in the text form
+// accepted by parseColor. Decoding the colors separately, rather than
+// through Color.UnmarshalText, lets errors name the field.
+type themeFile struct {
+	Name    string `json:"name"`
+	Prefix  string `json:"prefix"`
+	Info    string `json:"info"`
+	Debug   string `json:"debug"`
+	Trace   string `json:"trace"`
+	Warn    string `json:"warn"`
+	Error   string `json:"error"`
+	Success string `json:"success"`
+}
+
+// ParseTheme reads a Theme from a JSON document such as:
+//
+//	{
+//	  "name": "Ocean",
+//	  "prefix": "#5FAFD7",
+//	  "info": "default",
+//	  "debug": "bright-black",
+//	  "trace": "256:240",
+//	  "warn": "#FFD75F",
+//	  "error": "bold #FF5F5F",
+//	  "success": "green"
+//	}
+//
+// Each color may be written in any form accepted by ParseColor: hex,
+// rgb() or hsl(), an ANSI or CSS name, "256:N" for entry N of the
+// 256-color palette, or "default", preceded by text attributes such as
+// "bold" and followed by "on" and a background color if needed. Fields
+// that are left out use ColorDefault. Unknown fields, and colors that
+// cannot be parsed, are reported as errors that name the field.
+func ParseTheme(data []byte) (Theme, error) {
+	t, err := parseTheme(data)
+	if err != nil {
+		return Theme{}, fmt.Errorf("cliout: theme: %w", err)
+	}
+	return t, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading t in the format
+// described by ParseTheme so that invalid colors are reported with the
+// field that holds them.
+func (t *Theme) UnmarshalJSON(data []byte) error {
+	parsed, err := parseTheme(data)
+	if err != nil {
+		return fmt.Errorf("cliout: theme: %w", err)
+	}
+	*t = parsed
+	return nil
+}
+
+// LoadTheme reads a Theme from the JSON file at path, in the format
+// described by ParseTheme. If the file does not name the theme, it is named
+// after the file, without its extension. Errors name the file.
+func LoadTheme(path string) (Theme, error) {
+	t, err := loadTheme(path)
+	if err != nil {
+		return Theme{}, fmt.Errorf("cliout: %w", err)
+	}
+	return t, nil
+}
+
+// loadTheme implements LoadTheme, returning errors without the package
+// prefix so that callers can add context.
+func loadTheme(path string) (Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, err
+	}
+	t, err := parseTheme(data)
+	if err != nil {
+		return Theme{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return t, nil
+}
+
+// isThemePath reports whether the CLI_THEME value s is the path of a theme
+// file rather than a theme name: it contains a path separator or ends in
+// ".json".
+func isThemePath(s string) bool {
+	return strings.ContainsRune(s, '/') || strings.ContainsRune(s, filepath.Separator) ||
+		strings.HasSuffix(strings.ToLower(s), ".json")
+}
+
+// parseTheme decodes a theme document, which must hold a single object.
+// Errors point at the line and column of JSON syntax errors and of data
+// after the object, or name the field holding an invalid color.
+func parseTheme(data []byte) (Theme, error) {
+	var f themeFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			// Offset counts the bytes read, including the offending one.
+			line, col := position(data, syntaxErr.Offset-1)
+			return Theme{}, fmt.Errorf("line %d, column %d: %w", line, col, err)
+		}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return Theme{}, fmt.Errorf("%q: expected a string, got a JSON %s", typeErr.Field, typeErr.Value)
+		}
+		return Theme{}, err
+	}
+	rest := data[dec.InputOffset():]
+	if trimmed := bytes.TrimLeft(rest, " \t\r\n"); len(trimmed) > 0 {
+		line, col := position(data, int64(len(data)-len(trimmed)))
+		return Theme{}, fmt.Errorf("line %d, column %d: unexpected data after the theme object", line, col)
+	}
+
+	t := Theme{Name: f.Name}
+	fields := []struct {
+		key   string
+		value string
+		dst   *Color
+	}{
+		{"prefix", f.Prefix, &t.PrefixColor},
+		{"info", f.Info, &t.InfoColor},
+		{"debug", f.Debug, &t.DebugColor},
+		{"trace", f.Trace, &t.TraceColor},
+		{"warn", f.Warn, &t.WarnColor},
+		{"error", f.Error, &t.ErrorColor},
+		{"success", f.Success, &t.SuccessColor},
+	}
+	for _, field := range fields {
+		c, err := parseColor(field.value)
+		if err != nil {
+			return Theme{}, fmt.Errorf("%q: %w", field.key, err)
+		}
+		*field.dst = c
+	}
+	return t, nil
+}
+
+// position returns the 1-based line and column of the byte at offset in
+// data.
+func position(data []byte, offset int64) (line, col int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
@@ -0,0 +1,134 @@
+package cliout
+
+// TreeStyle selects the glyphs used to draw the branches of a tree.
+type TreeStyle int
+
+const (
+	// TreeUnicode draws branches with Unicode box-drawing characters.
+	TreeUnicode TreeStyle = iota
+	// TreeASCII draws branches with plain ASCII characters.
+	TreeASCII
+)
+
+// treeGlyphs holds the branch glyphs for one tree style.
+type treeGlyphs struct {
+	branch string // a child with further siblings below it
+	last   string // the last child of its parent
+	pipe   string // continuation of a parent's branch
+	space  string // indentation below a parent's last child
+}
+
+var treeStyles = map[TreeStyle]treeGlyphs{
+	TreeUnicode: {branch: "├── ", last: "└── ", pipe: "│   ", space: "    "},
+	TreeASCII:   {branch: "|-- ", last: "`-- ", pipe: "|   ", space: "    "},
+}
+
+// TreeNode is a node in a tree printed by Output.Tree.
+type TreeNode struct {
+	// Text is the node's label.
+	Text string
+	// Level selects the node's color from the theme and filters it: nodes
+	// below the Output's level are hidden along with their children. The
+	// zero value, LevelTrace, is treated as LevelInfo so that nodes written
+	// as literals are shown by default; use SetLevel for a trace-level node.
+	Level Level
+	// Children are the node's child nodes, drawn in order.
+	Children []*TreeNode
+
+	levelSet bool // Level was set by SetLevel, so LevelTrace is meant
+}
+
+// SetLevel sets the node's level and returns the node. Unlike assigning to
+// Level, it can make a node trace-level.
+func (n *TreeNode) SetLevel(l Level) *TreeNode {
+	n.Level = l
+	n.levelSet = true
+	return n
+}
+
+// level returns the level the node is filtered and colored at.
+func (n *TreeNode) level() Level {
+	if n.Level == LevelTrace && !n.levelSet {
+		return LevelInfo
+	}
+	return n.Level
+}
+
+// Node creates an info-level tree node with the given children.
+func Node(text string, children ...*TreeNode) *TreeNode {
+	return &TreeNode{Text: text, Level: LevelInfo, Children: children}
+}
+
+// Add appends an info-level child with the given text and returns it, so
+// that trees can be built up in loops.
+func (n *TreeNode) Add(text string) *TreeNode {
+	child := Node(text)
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// Tree prints a tree using Unicode branch glyphs. Each line is written to
+// the Info destination with the Output's prefix; branches use the theme's
+// PrefixColor and each node's text uses the color for its Level.
+func (o *Output) Tree(root *TreeNode) {
+	o.TreeWithStyle(root, TreeUnicode)
+}
+
+// TreeWithStyle prints a tree like Tree, using the given branch glyphs.
+func (o *Output) TreeWithStyle(root *TreeNode, style TreeStyle) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if root == nil || root.level() < o.level {
+		return
+	}
+	glyphs, ok := treeStyles[style]
+	if !ok {
+		glyphs = treeStyles[TreeUnicode]
+	}
+	o.printTreeNode(root, 0, "", "", glyphs)
+}
+
+// printTreeNode writes n, drawn after branch, and then its visible children
+// with guide lines continuing from indent. In JSON mode each node is instead
+// emitted as a message at its own level with o's fields and a "depth"
+// field. The caller must hold o.mu.
+func (o *Output) printTreeNode(n *TreeNode, depth int, indent, branch string, glyphs treeGlyphs) {
+	if o.format == FormatJSON {
+		fields := append(append([]Field{}, o.fields...), F("depth", depth))
+		o.writeLine(LevelInfo, jsonLine(n.level(), n.Text, false, fields))
+	} else {
+		o.printTreeLine(n, indent+branch)
+	}
+
+	if branch == glyphs.branch {
+		indent += glyphs.pipe
+	} else if branch == glyphs.last {
+		indent += glyphs.space
+	}
+
+	visible := make([]*TreeNode, 0, len(n.Children))
+	for _, c := range n.Children {
+		if c != nil && c.level() >= o.level {
+			visible = append(visible, c)
+		}
+	}
+	for i, c := range visible {
+		b := glyphs.branch
+		if i == len(visible)-1 {
+			b = glyphs.last
+		}
+		o.printTreeNode(c, depth+1, indent, b, glyphs)
+	}
+}
+
+// printTreeLine writes a single node drawn after its guide lines. The caller
+// must hold o.mu.
+func (o *Output) printTreeLine(n *TreeNode, guide string) {
+	colorEnabled := o.colorFor(LevelInfo)
+	line := o.renderPrefix(colorEnabled)
+	if guide != "" {
+		line += o.paint(o.currentPrefixColor(), guide, colorEnabled)
+	}
+	line += o.paint(o.messageColorFor(n.level(), false), n.Text, colorEnabled)
+	o.writeLine(LevelInfo, line)
+}